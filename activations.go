@@ -0,0 +1,97 @@
+package etcdcron
+
+import (
+	"iter"
+	"time"
+)
+
+// PrevSchedule is a Schedule which also gives its previous activation times,
+// like SpecSchedule and ConstantDelaySchedule.
+type PrevSchedule interface {
+	Schedule
+	// Return the previous activation time, earlier than the given time.
+	Prev(time.Time) time.Time
+}
+
+// NextN returns the next n activation times of the schedule, later than from.
+// Fewer times are returned if the schedule has no more activation, none if n is
+// not positive.
+func NextN(schedule Schedule, from time.Time, n int) []time.Time {
+	if n <= 0 {
+		return []time.Time{}
+	}
+	activations := make([]time.Time, 0, n)
+	for t := range Activations(schedule, from, time.Time{}) {
+		if len(activations) == n {
+			break
+		}
+		activations = append(activations, t)
+	}
+	return activations
+}
+
+// Activations returns an iterator over the activation times of the schedule
+// later than from and earlier than until. If until is the zero time, the
+// iteration stops only once the schedule has no more activation.
+func Activations(schedule Schedule, from, until time.Time) iter.Seq[time.Time] {
+	return func(yield func(time.Time) bool) {
+		for t := schedule.Next(from); !t.IsZero() && (until.IsZero() || t.Before(until)); t = schedule.Next(t) {
+			if !yield(t) {
+				return
+			}
+		}
+	}
+}
+
+// Prev returns the previous activation time of the schedule, earlier than the
+// given time, with the same daylight saving time semantics as Next. If no time
+// can be found within five years (or within the years of the schedule), it
+// returns the zero time.
+//
+// The returned time is in the location of the given time.
+func (s *SpecSchedule) Prev(t time.Time) time.Time {
+	limit := t.AddDate(-5, 0, 0)
+	if s.Year != nil {
+		// With a day of margin for the time zones
+		limit = time.Date(s.firstYear(), time.January, 1, 0, 0, 0, 0, time.UTC).Add(-24 * time.Hour)
+	}
+
+	// Look further and further back for an instant whose next activation is
+	// earlier than t.
+	var lo time.Time
+	for window := time.Second; ; window *= 2 {
+		lo = t.Add(-window)
+		if lo.Before(limit) {
+			lo = limit
+		}
+		if next := s.Next(lo); !next.IsZero() && next.Before(t) {
+			break
+		}
+		if lo.Equal(limit) {
+			return time.Time{}
+		}
+	}
+
+	// There is an activation between lo and hi, find the last one by bisection.
+	hi := t
+	for {
+		activation := s.Next(lo)
+		following := s.Next(activation)
+		if following.IsZero() || !following.Before(hi) {
+			return activation
+		}
+		mid := activation.Add(hi.Sub(activation) / 2)
+		if next := s.Next(mid); !next.IsZero() && next.Before(hi) {
+			lo = mid
+		} else {
+			// No activation between mid and hi.
+			hi = mid.Add(time.Nanosecond)
+		}
+	}
+}
+
+// Prev returns the time the schedule would have been activated before the
+// given time, rounded on the second like Next.
+func (schedule ConstantDelaySchedule) Prev(t time.Time) time.Time {
+	return t.Add(-schedule.Delay - time.Duration(t.Nanosecond())*time.Nanosecond)
+}
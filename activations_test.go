@@ -0,0 +1,153 @@
+package etcdcron
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"testing"
+	"time"
+)
+
+func TestNextN(t *testing.T) {
+	schedule, _ := Parse("0 0 9 * * mon-fri")
+	from := getTime("Fri Jul 13 10:00 2012")
+	expected := []time.Time{
+		getTime("Mon Jul 16 09:00 2012"),
+		getTime("Tue Jul 17 09:00 2012"),
+		getTime("Wed Jul 18 09:00 2012"),
+	}
+	actual := NextN(schedule, from, 3)
+	if fmt.Sprint(actual) != fmt.Sprint(expected) {
+		t.Errorf("(expected) %v != %v (actual)", expected, actual)
+	}
+
+	// Less activations than requested
+	schedule, _ = Parse("0 0 0 1 1 ? 2013-2014")
+	actual = NextN(schedule, from, 3)
+	expected = []time.Time{getTime("Tue Jan 1 00:00 2013"), getTime("Wed Jan 1 00:00 2014")}
+	if fmt.Sprint(actual) != fmt.Sprint(expected) {
+		t.Errorf("(expected) %v != %v (actual)", expected, actual)
+	}
+
+	for _, n := range []int{0, -1} {
+		if actual := NextN(Every(time.Minute), from, n); actual == nil || len(actual) != 0 {
+			t.Errorf("%d => expected no activation, got %#v", n, actual)
+		}
+	}
+}
+
+func TestActivations(t *testing.T) {
+	schedule, _ := Parse("0 */20 * * * *")
+	from := getTime("Mon Jul 9 14:00 2012")
+	until := getTime("Mon Jul 9 15:00 2012")
+
+	actual := []time.Time{}
+	for activation := range Activations(schedule, from, until) {
+		actual = append(actual, activation)
+	}
+	expected := []time.Time{getTime("Mon Jul 9 14:20 2012"), getTime("Mon Jul 9 14:40 2012")}
+	if fmt.Sprint(actual) != fmt.Sprint(expected) {
+		t.Errorf("(expected) %v != %v (actual)", expected, actual)
+	}
+
+	// Without end, until the caller stops
+	count := 0
+	for range Activations(schedule, from, time.Time{}) {
+		count++
+		if count == 100 {
+			break
+		}
+	}
+	if count != 100 {
+		t.Errorf("expected 100 activations, got %d", count)
+	}
+}
+
+func TestPrev(t *testing.T) {
+	runs := []struct {
+		time, spec string
+		expected   string
+	}{
+		// Simple cases
+		{"Mon Jul 9 14:45 2012", "0 0/15 * * *", "Mon Jul 9 14:30 2012"},
+		{"Mon Jul 9 14:46 2012", "0 0/15 * * *", "Mon Jul 9 14:45 2012"},
+		{"Mon Jul 9 14:45:01 2012", "0 0/15 * * *", "Mon Jul 9 14:45 2012"},
+		{"Mon Jul 9 14:45 2012", "* * * * * *", "Mon Jul 9 14:44:59 2012"},
+
+		// Wrap around days, months and years
+		{"Mon Jul 9 00:10 2012", "0 20-35/15 * * *", "Sun Jul 8 23:35 2012"},
+		{"Mon Jul 9 23:35 2012", "0 0 0 9 Apr-Jun ?", "Sat Jun 9 00:00 2012"},
+		{"Mon Jul 9 23:35 2012", "0 0 0 * Aug Mon", "Mon Aug 29 00:00 2011"},
+		{"Tue Jan 1 00:00 2013", "0 * * * * *", "Mon Dec 31 23:59 2012"},
+
+		// Leap year
+		{"Mon Jul 9 23:35 2012", "0 0 0 29 Feb ?", "Wed Feb 29 00:00 2012"},
+
+		// Modifiers and years
+		{"Mon Jul 9 23:35 2012", "0 0 0 L * ?", "Sat Jun 30 00:00 2012"},
+		{"Mon Jul 9 23:35 2012", "0 0 0 ? * 5L", "Fri Jun 29 00:00 2012"},
+		{"Mon Jul 9 23:35 2012", "0 0 0 1 Jan ? 1990-2000/5", "Sat Jan 1 00:00 2000"},
+		{"Mon Jul 9 23:35 2012", "0 0 0 1 Jan ? 2013", ""},
+
+		// Daylight savings time EST -> EDT: 02:30 is skipped, run at 03:00
+		{"2012-03-11T04:00:00-0400", "0 30 2 11 Mar ?", "2012-03-11T03:00:00-0400"},
+
+		// Daylight savings time EDT -> EST: 01:30 is repeated, run once
+		{"2012-11-04T02:00:00-0500", "0 30 1 04 Nov ?", "2012-11-04T01:30:00-0400"},
+
+		// Unsatisfiable
+		{"Mon Jul 9 23:35 2012", "0 0 0 30 Feb ?", ""},
+	}
+
+	for _, c := range runs {
+		sched, err := Parse(c.spec)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		actual := sched.(PrevSchedule).Prev(getTime(c.time))
+		expected := getTime(c.expected)
+		if !actual.Equal(expected) {
+			t.Errorf("%s, \"%s\": (expected) %v != %v (actual)", c.time, c.spec, expected, actual)
+		}
+	}
+
+	at := getTime("Mon Jul 9 14:45:00 2012")
+	if actual := Every(time.Minute).Prev(at.Add(500 * time.Millisecond)); !actual.Equal(at.Add(-time.Minute)) {
+		t.Errorf("(expected) %v != %v (actual)", at.Add(-time.Minute), actual)
+	}
+}
+
+// Test that Prev gives the last activation given by Next before a time, for
+// random specs and times.
+func TestPrevNext(t *testing.T) {
+	r := rand.New(rand.NewPCG(3, 4))
+	start := time.Date(2012, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 2000; i++ {
+		spec := randomSpec(r)
+		schedule, err := Parse(spec)
+		if err != nil {
+			t.Fatalf("%s => unexpected error %v", spec, err)
+		}
+		prevSchedule, ok := schedule.(*SpecSchedule)
+		if !ok {
+			continue
+		}
+		at := start.Add(time.Duration(r.Int64N(int64(20 * 365 * 24 * time.Hour))))
+		prev := prevSchedule.Prev(at)
+		if prev.IsZero() {
+			if next := schedule.Next(at.AddDate(-5, 0, 0)); !next.IsZero() && next.Before(at) && prevSchedule.Year == nil {
+				t.Errorf("%s, %v => unexpected zero time, %v is earlier", spec, at, next)
+			}
+			continue
+		}
+		if !prev.Before(at) {
+			t.Errorf("%s, %v => %v is not earlier", spec, at, prev)
+		}
+		if next := schedule.Next(prev); !next.IsZero() && next.Before(at) {
+			t.Errorf("%s, %v => %v is not the last activation, %v is later", spec, at, prev, next)
+		}
+		if next := schedule.Next(prev.Add(-time.Second)); !next.Equal(prev) {
+			t.Errorf("%s, %v => %v is not an activation, %v is", spec, at, prev, next)
+		}
+	}
+}
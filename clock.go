@@ -0,0 +1,149 @@
+package etcdcron
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock provides the current time and the timers used by the scheduler. It
+// can be replaced with WithClock, for instance by a FakeClock in tests.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is the Clock equivalent of a time.Timer.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// realClock is the Clock based on the time package, used by default.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{Timer: time.NewTimer(d)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+// FakeClock is a Clock whose time only changes when calling Advance or Set. It
+// lets tests trigger the scheduled entries without waiting.
+type FakeClock struct {
+	mutex  sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+	// changed is closed and replaced each time a timer is created or stopped.
+	changed chan struct{}
+}
+
+// NewFakeClock returns a FakeClock whose current time is now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now, changed: make(chan struct{})}
+}
+
+// Now returns the current time of the fake clock.
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// NewTimer returns a timer firing once the fake clock has been advanced by d.
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	t := &fakeTimer{clock: c, deadline: c.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.c <- c.now
+		return t
+	}
+	c.timers = append(c.timers, t)
+	c.notify()
+	return t
+}
+
+// Advance moves the current time forward by d, firing the timers expiring in
+// the meantime.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+// Set changes the current time, firing the timers expiring before it.
+func (c *FakeClock) Set(now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = now
+	sort.Slice(c.timers, func(i, j int) bool {
+		return c.timers[i].deadline.Before(c.timers[j].deadline)
+	})
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.deadline.After(now) {
+			pending = append(pending, t)
+			continue
+		}
+		t.c <- now
+	}
+	c.timers = pending
+	c.notify()
+}
+
+// BlockUntil blocks until at least n timers are waiting on the fake clock. It
+// is used to make sure the scheduler is sleeping before advancing the time.
+func (c *FakeClock) BlockUntil(n int) {
+	for {
+		c.mutex.Lock()
+		waiting := len(c.timers)
+		changed := c.changed
+		c.mutex.Unlock()
+
+		if waiting >= n {
+			return
+		}
+		<-changed
+	}
+}
+
+// notify wakes up the BlockUntil callers, the mutex must be held.
+func (c *FakeClock) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+type fakeTimer struct {
+	clock    *FakeClock
+	deadline time.Time
+	c        chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+
+	for i, timer := range t.clock.timers {
+		if timer == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			t.clock.notify()
+			return true
+		}
+	}
+	return false
+}
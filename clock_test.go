@@ -0,0 +1,51 @@
+package etcdcron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClockTimers(t *testing.T) {
+	start := time.Date(2012, time.July, 9, 14, 45, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	t1 := clock.NewTimer(time.Second)
+	t2 := clock.NewTimer(time.Minute)
+	t3 := clock.NewTimer(time.Hour)
+	clock.BlockUntil(3)
+
+	if !t3.Stop() {
+		t.Error("expected t3 to be stopped")
+	}
+
+	clock.Advance(time.Minute)
+	for _, timer := range []Timer{t1, t2} {
+		select {
+		case now := <-timer.C():
+			if !now.Equal(start.Add(time.Minute)) {
+				t.Errorf("unexpected firing time %v", now)
+			}
+		default:
+			t.Error("expected timer to fire")
+		}
+	}
+	if t1.Stop() {
+		t.Error("expected fired timer not to be stoppable")
+	}
+
+	clock.Advance(time.Hour)
+	select {
+	case <-t3.C():
+		t.Error("expected stopped timer not to fire")
+	default:
+	}
+}
+
+func TestFakeClockImmediateTimer(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	select {
+	case <-clock.NewTimer(-time.Second).C():
+	default:
+		t.Error("expected timer with a negative duration to fire immediately")
+	}
+}
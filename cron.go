@@ -3,11 +3,15 @@ package etcdcron
 import (
 	"context"
 	"fmt"
+	"hash/fnv"
+	"io"
 	"log"
 	"regexp"
 	"runtime/debug"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/iancoleman/strcase"
@@ -17,6 +21,10 @@ import (
 
 const (
 	defaultEtcdEndpoint = "127.0.0.1:2379"
+	defaultLockTimeout  = time.Second
+	defaultKeyPrefix    = "etcd_cron"
+	// watchRetryDelay is the delay before watching etcd again after an error.
+	watchRetryDelay = time.Second
 )
 
 // Cron keeps track of any number of entries, invoking the associated func as
@@ -25,13 +33,114 @@ const (
 type Cron struct {
 	entries           []*Entry
 	stop              chan struct{}
-	add               chan *Entry
+	add               chan jobRequest
+	replace           chan jobRequest
+	remove            chan jobRequest
+	lookup            chan jobRequest
 	snapshot          chan []*Entry
 	etcdErrorsHandler func(context.Context, Job, error)
 	errorsHandler     func(context.Context, Job, error)
+	skipHandler       func(context.Context, Job, error)
 	funcCtx           func(context.Context, Job) context.Context
 	running           bool
 	etcdclient        EtcdMutexBuilder
+	// ownsEtcdClient is true if the mutex builder has been created by New, and
+	// must be closed with the Cron.
+	ownsEtcdClient    bool
+	shutdownMode      ShutdownMode
+	clock             Clock
+	location          *time.Location
+	parser            Parser
+	defaultJobTimeout time.Duration
+	lockTimeout       time.Duration
+	keyPrefix         string
+	janitorInterval   time.Duration
+	janitor           janitor
+	nodeID            string
+	historyLimit      int
+
+	// jobDefinitions is true if the jobs defined in etcd under the definitions
+	// prefix are scheduled.
+	jobDefinitions    bool
+	definitionsPrefix string
+	handlers          handlers
+	definitions       chan []definitionEvent
+	triggers          chan triggerRequest
+	// definedJobs are the canonical names of the jobs added from their
+	// definition in etcd.
+	definedJobs map[string]bool
+	paused      pausedJobs
+	misfires    misfires
+
+	// executions tracks the goroutines running a job iteration.
+	executions        sync.WaitGroup
+	executionsMutex   sync.Mutex
+	runningExecutions map[*execution]struct{}
+	shuttingDown      bool
+	// shutdown is closed once Shutdown is called, to wake up the iterations
+	// waiting to start.
+	shutdown chan struct{}
+
+	overlapMutex sync.Mutex
+	overlaps     map[string]*overlapState
+}
+
+// ShutdownMode defines how Shutdown handles the running executions.
+type ShutdownMode int
+
+const (
+	// ShutdownWait waits for the running executions to finish.
+	ShutdownWait ShutdownMode = iota
+	// ShutdownCancel cancels the context of the running executions, then waits
+	// for them to return.
+	ShutdownCancel
+)
+
+// ErrShuttingDown is returned by Trigger once Shutdown has been called. It's
+// also given to the skipped iterations handler for the iterations which were
+// waiting to start, ie. because of the jitter or the overlap policy of their
+// job, when Shutdown is called.
+var ErrShuttingDown = errors.New("cron shutting down")
+
+// ShutdownError is returned by Shutdown when its context expires before all
+// the running executions are finished.
+type ShutdownError struct {
+	// RunningJobs contains the names of the jobs which were still running.
+	RunningJobs []string
+	Err         error
+}
+
+func (e *ShutdownError) Error() string {
+	return fmt.Sprintf("shutdown: %v, jobs still running: %s", e.Err, strings.Join(e.RunningJobs, ", "))
+}
+
+func (e *ShutdownError) Unwrap() error {
+	return e.Err
+}
+
+// TimeoutError is given to the errors handler when an execution exceeds the
+// deadline defined by the Timeout options of its job.
+type TimeoutError struct {
+	Timeout time.Duration
+	// Err is the error returned by the job, if any.
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("job timed out after %v", e.Timeout)
+	}
+	return fmt.Sprintf("job timed out after %v: %v", e.Timeout, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// execution is a running iteration of a job.
+type execution struct {
+	job    Job
+	cancel context.CancelFunc
 }
 
 // Job contains 3 mandatory options to define a job
@@ -42,6 +151,32 @@ type Job struct {
 	Rhythm string
 	// Routine method
 	Func func(context.Context) error
+	// Maximum duration of an execution, the context given to Func is canceled
+	// after it (optional, the default timeout of the cron is used if zero)
+	Timeout time.Duration
+	// Cancel the context given to Func at the next activation time of the job,
+	// if it is earlier than Timeout (optional)
+	TimeoutAtNextActivation bool
+	// Retry defines how the failed executions are retried (optional, no retry
+	// by default)
+	Retry *RetryPolicy
+	// Overlap defines what happens when an iteration is due while the previous
+	// one is still running, on any node (optional, OverlapAllow by default)
+	Overlap OverlapPolicy
+	// Misfire defines what happens to the iterations missed by the whole
+	// cluster (optional, MisfireIgnore by default)
+	Misfire MisfirePolicy
+	// Maximum number of missed iterations run by the MisfireRunAll policy
+	// (optional, 10 by default)
+	MisfireLimit int
+	// Location in which the rhythm is evaluated, unless it has a CRON_TZ=
+	// prefix (optional, the location of the cron is used if nil)
+	Location *time.Location
+	// Maximum delay of the scheduled iterations, derived from the job name and
+	// the activation time so that it's the same on all the nodes. It should be
+	// shorter than the interval between two activations (optional, no delay by
+	// default)
+	Jitter time.Duration
 }
 
 func (j Job) Run(ctx context.Context) error {
@@ -50,6 +185,13 @@ func (j Job) Run(ctx context.Context) error {
 
 var (
 	nonAlphaNumerical = regexp.MustCompile("[^a-z0-9_]")
+
+	// ErrJobAlreadyExists is returned when a job with the same canonical name
+	// has already been added to the Cron: both jobs would share the same etcd
+	// lock keys.
+	ErrJobAlreadyExists = errors.New("job already exists")
+	// ErrJobNotFound is returned when no job matches the given name.
+	ErrJobNotFound = errors.New("job not found")
 )
 
 func (j Job) canonicalName() string {
@@ -77,12 +219,17 @@ type Entry struct {
 	// started or this entry's schedule is unsatisfiable
 	Next time.Time
 
-	// The last time this job was run. This is the zero time if the job has never
-	// been run.
+	// The last time this job was run. Until the job is run by this process, it's
+	// the previous activation time of its schedule if it implements
+	// PrevSchedule, the zero time otherwise.
 	Prev time.Time
 
 	// The Job o run.
 	Job Job
+
+	// True if the job has been paused with Pause, on any node. The paused state
+	// is watched in etcd while the cron is running.
+	Paused bool
 }
 
 // byTime is a wrapper for sorting the entry array by time
@@ -104,6 +251,19 @@ func (s byTime) Less(i, j int) bool {
 	return s[i].Next.Before(s[j].Next)
 }
 
+// jobRequest is sent to the scheduler goroutine to add, replace, remove or
+// look up an entry while the Cron is running.
+type jobRequest struct {
+	entry *Entry
+	name  string
+	reply chan jobReply
+}
+
+type jobReply struct {
+	entry *Entry
+	err   error
+}
+
 type CronOpt func(cron *Cron)
 
 func WithEtcdErrorsHandler(f func(context.Context, Job, error)) CronOpt {
@@ -118,12 +278,81 @@ func WithErrorsHandler(f func(context.Context, Job, error)) CronOpt {
 	})
 }
 
+// WithSkippedIterationsHandler sets a function called each time this process
+// skips an iteration it was about to run, the error gives the reason (ie.
+// ErrIterationOverlaps or ErrJobPaused).
+func WithSkippedIterationsHandler(f func(context.Context, Job, error)) CronOpt {
+	return CronOpt(func(cron *Cron) {
+		cron.skipHandler = f
+	})
+}
+
 func WithEtcdMutexBuilder(b EtcdMutexBuilder) CronOpt {
 	return CronOpt(func(cron *Cron) {
 		cron.etcdclient = b
 	})
 }
 
+// WithShutdownMode defines whether Shutdown waits for the running executions
+// (ShutdownWait, the default) or cancels them (ShutdownCancel).
+func WithShutdownMode(mode ShutdownMode) CronOpt {
+	return CronOpt(func(cron *Cron) {
+		cron.shutdownMode = mode
+	})
+}
+
+// WithDefaultJobTimeout sets the timeout of the executions of the jobs without
+// a Timeout.
+func WithDefaultJobTimeout(timeout time.Duration) CronOpt {
+	return CronOpt(func(cron *Cron) {
+		cron.defaultJobTimeout = timeout
+	})
+}
+
+// WithLockTimeout sets the maximum duration to acquire the lock of an
+// iteration (1 second by default). Past this duration, the iteration is
+// considered as run by another node. It must be longer than the latency of the
+// etcd cluster.
+func WithLockTimeout(timeout time.Duration) CronOpt {
+	return CronOpt(func(cron *Cron) {
+		cron.lockTimeout = timeout
+	})
+}
+
+// WithKeyPrefix sets the prefix of the keys created in etcd ("etcd_cron" by
+// default). Applications sharing an etcd cluster should use different
+// prefixes.
+func WithKeyPrefix(prefix string) CronOpt {
+	return CronOpt(func(cron *Cron) {
+		cron.keyPrefix = prefix
+	})
+}
+
+// WithParser sets the parser of the rhythms of the jobs, Parse is used by
+// default.
+func WithParser(parser Parser) CronOpt {
+	return CronOpt(func(cron *Cron) {
+		cron.parser = parser
+	})
+}
+
+// WithLocation sets the location in which the rhythms of the jobs are
+// evaluated (time.Local by default). All the nodes of a cluster should use the
+// same location, so that they compute the same activation times.
+func WithLocation(location *time.Location) CronOpt {
+	return CronOpt(func(cron *Cron) {
+		cron.location = location
+	})
+}
+
+// WithClock replaces the clock used to schedule the entries, mostly useful in
+// tests with a FakeClock.
+func WithClock(clock Clock) CronOpt {
+	return CronOpt(func(cron *Cron) {
+		cron.clock = clock
+	})
+}
+
 func WithFuncCtx(f func(context.Context, Job) context.Context) CronOpt {
 	return CronOpt(func(cron *Cron) {
 		cron.funcCtx = f
@@ -133,15 +362,46 @@ func WithFuncCtx(f func(context.Context, Job) context.Context) CronOpt {
 // New returns a new Cron job runner.
 func New(opts ...CronOpt) (*Cron, error) {
 	cron := &Cron{
-		entries:  nil,
-		add:      make(chan *Entry),
-		stop:     make(chan struct{}),
-		snapshot: make(chan []*Entry),
-		running:  false,
+		entries:         nil,
+		lockTimeout:     defaultLockTimeout,
+		keyPrefix:       defaultKeyPrefix,
+		janitorInterval: defaultJanitorInterval,
+		historyLimit:    defaultHistoryLimit,
+		add:             make(chan jobRequest),
+		replace:         make(chan jobRequest),
+		remove:          make(chan jobRequest),
+		lookup:          make(chan jobRequest),
+		stop:            make(chan struct{}),
+		snapshot:        make(chan []*Entry),
+		definitions:     make(chan []definitionEvent),
+		triggers:        make(chan triggerRequest),
+		shutdown:        make(chan struct{}),
+		running:         false,
 	}
 	for _, opt := range opts {
 		opt(cron)
 	}
+	if cron.lockTimeout <= 0 {
+		return nil, errors.Errorf("invalid lock timeout %v, it must be positive", cron.lockTimeout)
+	}
+	cron.keyPrefix = strings.TrimSuffix(cron.keyPrefix, "/")
+	if cron.keyPrefix == "" {
+		return nil, errors.New("invalid empty key prefix")
+	}
+	if cron.historyLimit < 0 {
+		return nil, errors.Errorf("invalid history limit %v, it must be positive", cron.historyLimit)
+	}
+	if cron.nodeID == "" {
+		cron.nodeID = defaultNodeID()
+	}
+	if cron.janitorInterval < 0 {
+		return nil, errors.Errorf("invalid janitor interval %v, it must be positive", cron.janitorInterval)
+	}
+	if cron.jobDefinitions {
+		if err := cron.validateDefinitionsPrefix(); err != nil {
+			return nil, err
+		}
+	}
 	if cron.etcdclient == nil {
 		etcdClient, err := NewEtcdMutexBuilder(etcdclient.Config{
 			Endpoints: []string{defaultEtcdEndpoint},
@@ -150,6 +410,19 @@ func New(opts ...CronOpt) (*Cron, error) {
 			return nil, err
 		}
 		cron.etcdclient = etcdClient
+		cron.ownsEtcdClient = true
+	}
+	if _, ok := cron.etcdClient(); cron.jobDefinitions && !ok {
+		return nil, errors.Wrap(ErrNoEtcdClient, "job definitions require an etcd client")
+	}
+	if cron.clock == nil {
+		cron.clock = realClock{}
+	}
+	if cron.location == nil {
+		cron.location = time.Local
+	}
+	if cron.parser.options == 0 {
+		cron.parser = defaultParser
 	}
 	if cron.etcdErrorsHandler == nil {
 		cron.etcdErrorsHandler = func(ctx context.Context, j Job, err error) {
@@ -164,28 +437,90 @@ func New(opts ...CronOpt) (*Cron, error) {
 	return cron, nil
 }
 
-// AddFunc adds a Job to the Cron to be run on the given schedule.
+// AddJob adds a Job to the Cron to be run on the given schedule. It returns
+// ErrJobAlreadyExists if a job with the same canonical name has already been
+// added.
 func (c *Cron) AddJob(job Job) error {
-	schedule, err := Parse(job.Rhythm)
+	schedule, err := c.parseRhythm(job)
 	if err != nil {
 		return err
 	}
-	c.Schedule(schedule, job)
-	return nil
+	return c.Schedule(schedule, job)
+}
+
+// parseRhythm parses the rhythm of the job, its H tokens being resolved from
+// its canonical name.
+func (c *Cron) parseRhythm(job Job) (Schedule, error) {
+	return c.parser.WithHashKey(job.canonicalName()).Parse(job.Rhythm)
 }
 
-// Schedule adds a Job to the Cron to be run on the given schedule.
-func (c *Cron) Schedule(schedule Schedule, job Job) {
+// Schedule adds a Job to the Cron to be run on the given schedule. It returns
+// ErrJobAlreadyExists if a job with the same canonical name has already been
+// added.
+func (c *Cron) Schedule(schedule Schedule, job Job) error {
 	entry := &Entry{
 		Schedule: schedule,
 		Job:      job,
 	}
 	if !c.running {
-		c.entries = append(c.entries, entry)
-		return
+		return c.addEntry(entry, time.Time{})
+	}
+	return c.send(c.add, jobRequest{entry: entry}).err
+}
+
+// ReplaceJob replaces the job having the same canonical name as the given one,
+// parsing its rhythm again. The next activation time is computed from the new
+// schedule. It returns ErrJobNotFound if no such job exists.
+func (c *Cron) ReplaceJob(job Job) error {
+	schedule, err := c.parseRhythm(job)
+	if err != nil {
+		return err
+	}
+	entry := &Entry{
+		Schedule: schedule,
+		Job:      job,
+	}
+	if !c.running {
+		return c.replaceEntry(entry, time.Time{})
 	}
+	return c.send(c.replace, jobRequest{entry: entry}).err
+}
 
-	c.add <- entry
+// RemoveJob removes the job with the given name from the Cron. Executions
+// already started are not interrupted. It returns ErrJobNotFound if no such job
+// exists.
+func (c *Cron) RemoveJob(name string) error {
+	if !c.running {
+		return c.removeEntry(name)
+	}
+	return c.send(c.remove, jobRequest{name: name}).err
+}
+
+// Job returns the job registered with the given name. It returns
+// ErrJobNotFound if no such job exists.
+func (c *Cron) Job(name string) (Job, error) {
+	var entry *Entry
+	if c.running {
+		reply := c.send(c.lookup, jobRequest{name: name})
+		if reply.err != nil {
+			return Job{}, reply.err
+		}
+		entry = reply.entry
+	} else {
+		_, e, err := c.findEntry(name)
+		if err != nil {
+			return Job{}, err
+		}
+		entry = e
+	}
+	return entry.Job, nil
+}
+
+// send forwards the request to the scheduler goroutine and waits for its reply.
+func (c *Cron) send(ch chan jobRequest, req jobRequest) jobReply {
+	req.reply = make(chan jobReply, 1)
+	ch <- req
+	return <-req.reply
 }
 
 // Entries returns a snapshot of the cron entries.
@@ -207,10 +542,22 @@ func (c *Cron) Start(ctx context.Context) {
 // Run the scheduler.. this is private just due to the need to synchronize
 // access to the 'running' state variable.
 func (c *Cron) run(ctx context.Context) {
+	// The background routines are stopped with the scheduler.
+	backgroundCtx, stopBackground := context.WithCancel(ctx)
+	defer stopBackground()
+	go c.runJanitor(backgroundCtx)
+	go c.watchJobDefinitions(backgroundCtx)
+	go c.watchTriggerRequests(backgroundCtx)
+	go c.watchPausedJobs(backgroundCtx)
+
 	// Figure out the next activation times for each entry.
-	now := time.Now().Local()
+	now := c.clock.Now().Local()
 	for _, entry := range c.entries {
-		entry.Next = entry.Schedule.Next(now)
+		entry.Next = c.nextActivation(entry, now)
+		if entry.Prev.IsZero() {
+			entry.Prev = c.prevActivation(entry, now)
+		}
+		c.startCatchUp(ctx, entry)
 	}
 
 	for {
@@ -226,80 +573,541 @@ func (c *Cron) run(ctx context.Context) {
 			effective = c.entries[0].Next
 		}
 
+		timer := c.clock.NewTimer(effective.Sub(now))
 		select {
-		case now = <-time.After(effective.Sub(now)):
+		case now = <-timer.C():
 			// Run every entry whose next time was this effective time.
 			for _, e := range c.entries {
-				if e.Next != effective {
+				// The entries may have different locations.
+				if !e.Next.Equal(effective) {
 					break
 				}
 				e.Prev = e.Next
-				e.Next = e.Schedule.Next(effective)
-
-				go func(ctx context.Context, e *Entry) {
-					defer func() {
-						r := recover()
-						if r != nil {
-							err, ok := r.(error)
-							if !ok {
-								err = fmt.Errorf("%v", r)
-							}
-							err = fmt.Errorf("panic: %v, stacktrace: %s", err, string(debug.Stack()))
-							go c.errorsHandler(ctx, e.Job, err)
-						}
-					}()
-
-					if c.funcCtx != nil {
-						ctx = c.funcCtx(ctx, e.Job)
-					}
-
-					m, err := c.etcdclient.NewMutex(fmt.Sprintf("etcd_cron/%s/%d", e.Job.canonicalName(), effective.Unix()))
-					if err != nil {
-						go c.etcdErrorsHandler(ctx, e.Job, errors.Wrapf(err, "fail to create etcd mutex for job '%v'", e.Job.Name))
-						return
-					}
-					lockCtx, cancel := context.WithTimeout(ctx, time.Second)
-					defer cancel()
-
-					err = m.Lock(lockCtx)
-					if err == context.DeadlineExceeded {
-						return
-					} else if err != nil {
-						go c.etcdErrorsHandler(ctx, e.Job, errors.Wrapf(err, "fail to lock mutex '%v'", m.Key()))
-						return
-					}
-
-					err = e.Job.Run(ctx)
-					if err != nil {
-						go c.errorsHandler(ctx, e.Job, err)
-						return
-					}
-				}(ctx, e)
+				e.Next = c.nextActivation(e, effective)
+
+				c.executions.Add(1)
+				go c.execute(ctx, e.Job, e.Schedule, effective, e.Next)
 			}
 			continue
 
-		case newEntry := <-c.add:
-			c.entries = append(c.entries, newEntry)
-			newEntry.Next = newEntry.Schedule.Next(now)
+		case req := <-c.add:
+			err := c.addEntry(req.entry, now)
+			if err == nil {
+				c.startCatchUp(ctx, req.entry)
+			}
+			req.reply <- jobReply{err: err}
+
+		case req := <-c.replace:
+			req.reply <- jobReply{err: c.replaceEntry(req.entry, now)}
+
+		case req := <-c.remove:
+			req.reply <- jobReply{err: c.removeEntry(req.name)}
+
+		case req := <-c.lookup:
+			_, entry, err := c.findEntry(req.name)
+			req.reply <- jobReply{entry: entry, err: err}
 
 		case <-c.snapshot:
 			c.snapshot <- c.entrySnapshot()
 
+		case events := <-c.definitions:
+			c.applyDefinitionEvents(ctx, events, now)
+
+		case request := <-c.triggers:
+			// Only the nodes having the job try to claim the request.
+			_, entry, err := c.findEntry(request.Job)
+			if err == nil {
+				c.executions.Add(1)
+				go c.handleTriggerRequest(ctx, entry.Job, request)
+			}
+
 		case <-c.stop:
+			timer.Stop()
 			return
 		}
+		timer.Stop()
 
 		// 'now' should be updated after newEntry and snapshot cases.
-		now = time.Now().Local()
+		now = c.clock.Now().Local()
 	}
 }
 
-// Stop the cron scheduler.
+// execute runs one iteration of the job scheduled at the given effective
+// time, if this process is the one acquiring the iteration lock. next is the
+// following activation time of the job.
+func (c *Cron) execute(ctx context.Context, job Job, schedule Schedule, effective, next time.Time) {
+	defer c.executions.Done()
+	c.runIteration(ctx, job, iteration{
+		lockName:    strconv.FormatInt(effective.Unix(), 10),
+		scheduledAt: effective,
+		next:        next,
+		schedule:    schedule,
+		delay:       jitterDelay(job, effective),
+	})
+}
+
+// jitterDelay returns the delay of the iteration of the job scheduled at the
+// given time, between 0 and the jitter of the job. It's derived from the
+// canonical name of the job and the activation time, so that all the nodes
+// delay the iteration identically.
+func jitterDelay(job Job, scheduledAt time.Time) time.Duration {
+	if job.Jitter <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s/%d", job.canonicalName(), scheduledAt.Unix())
+	return time.Duration(h.Sum64() % uint64(job.Jitter))
+}
+
+// iteration describes an execution of a job.
+type iteration struct {
+	// Name of the iteration lock, in the namespace of the job
+	lockName    string
+	scheduledAt time.Time
+	// Following activation time of the job, zero for manual iterations
+	next time.Time
+	// Manual iterations release their lock once run, as its name is unique
+	manual bool
+	// Schedule of the scheduled iterations, to catch up the iterations missed
+	// while etcd was unreachable
+	schedule Schedule
+	// Delay of the iteration, given by the jitter of the job. The lock name and
+	// the scheduled time are not delayed.
+	delay time.Duration
+}
+
+// runIteration runs the iteration if this process is the one acquiring its
+// lock. It returns the error of the job, or the reason why it has not been
+// run, which has already been given to the handlers.
+func (c *Cron) runIteration(ctx context.Context, job Job, it iteration) (err error) {
+	parentCtx := ctx
+	ctx, untrack := c.trackExecution(ctx, job)
+	defer untrack()
+
+	defer func() {
+		r := recover()
+		if r != nil {
+			panicErr, ok := r.(error)
+			if !ok {
+				panicErr = fmt.Errorf("%v", r)
+			}
+			err = fmt.Errorf("panic: %v, stacktrace: %s", panicErr, string(debug.Stack()))
+			go c.errorsHandler(ctx, job, err)
+		}
+	}()
+
+	ctx = context.WithValue(ctx, scheduledTimeKey{}, it.scheduledAt)
+	if c.funcCtx != nil {
+		ctx = c.funcCtx(ctx, job)
+	}
+
+	if it.delay > 0 {
+		timer := c.clock.NewTimer(it.delay)
+		select {
+		case <-timer.C():
+		case <-c.shutdown:
+			timer.Stop()
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+	// No iteration is started once Shutdown has been called. It's skipped before
+	// being locked, so that another node may still run it.
+	if c.isShuttingDown() {
+		c.skipIteration(ctx, job, ErrShuttingDown)
+		c.recordHandled(ctx, job, it)
+		return ErrShuttingDown
+	}
+
+	// Checked by all the nodes, so that none of them creates the iteration lock.
+	paused, err := c.isPaused(ctx, job)
+	if err != nil {
+		go c.etcdErrorsHandler(ctx, job, err)
+		// etcd is unreachable: while the session is alive, locking the iteration
+		// times out as if another process got the lock. The iterations missed in
+		// the meantime are caught up once etcd is reachable again.
+		if it.schedule != nil && ctx.Err() == nil {
+			c.misfires.setFailed(job, true)
+		}
+	} else if paused {
+		c.skipIteration(ctx, job, ErrJobPaused)
+		c.recordHandled(ctx, job, it)
+		return ErrJobPaused
+	}
+
+	m, err := c.etcdclient.NewMutex(c.jobKey(job, it.lockName))
+	if err != nil {
+		err = errors.Wrapf(err, "fail to create etcd mutex for job '%v'", job.Name)
+		go c.etcdErrorsHandler(ctx, job, err)
+		if it.schedule != nil {
+			c.misfires.setFailed(job, true)
+		}
+		return err
+	}
+	lockCtx, cancel := context.WithTimeout(ctx, c.lockTimeout)
+	defer cancel()
+
+	err = m.Lock(lockCtx)
+	if ctx.Err() != nil {
+		// The execution got canceled during shutdown.
+		return ctx.Err()
+	} else if err == context.DeadlineExceeded {
+		// Another process got the lock.
+		return errors.Wrapf(ErrIterationNotRun, "mutex '%v' locked by another process", m.Key())
+	} else if err != nil {
+		err = errors.Wrapf(err, "fail to lock mutex '%v'", m.Key())
+		go c.etcdErrorsHandler(ctx, job, err)
+		if it.schedule != nil {
+			c.misfires.setFailed(job, true)
+		}
+		return err
+	}
+	if it.manual {
+		defer c.unlockMutex(ctx, job, m)
+	}
+	// The iteration is handled by this node from now on, even if it is skipped
+	// by the overlap policy, fails or panics: it must not be caught up.
+	defer c.recordHandled(ctx, job, it)
+	if it.schedule != nil && c.misfires.setFailed(job, false) && job.Misfire != MisfireIgnore {
+		// etcd is reachable again, the iterations missed in the meantime are run
+		// in the background.
+		c.executions.Add(1)
+		go c.catchUp(parentCtx, job, it.schedule, it.scheduledAt, it.next)
+	}
+
+	ctx, release, ok := c.acquireOverlap(ctx, job, it.scheduledAt)
+	if !ok {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return errors.Wrap(ErrIterationNotRun, "overlap policy")
+	}
+	defer release()
+
+	execution := Execution{
+		Job:         job.Name,
+		ScheduledAt: it.scheduledAt,
+		NodeID:      c.nodeID,
+		StartedAt:   c.clock.Now(),
+	}
+	defer func() {
+		// The panic is recorded, then handled by the errors handler.
+		if r := recover(); r != nil {
+			execution.FinishedAt = c.clock.Now()
+			execution.Outcome = ExecutionPanic
+			execution.Error = fmt.Sprintf("panic: %v", r)
+			c.recordExecution(ctx, job, it.lockName, execution)
+			panic(r)
+		}
+	}()
+
+	err = c.runWithRetries(ctx, job, it.next)
+	execution.FinishedAt = c.clock.Now()
+	execution.Outcome = executionOutcome(err)
+	if err != nil {
+		execution.Error = err.Error()
+	}
+	c.recordExecution(ctx, job, it.lockName, execution)
+	return err
+}
+
+// runWithTimeout runs the job with the deadline defined by its timeout
+// options. If the deadline is exceeded, a *TimeoutError is returned.
+func (c *Cron) runWithTimeout(ctx context.Context, job Job, next time.Time) error {
+	timeout := job.Timeout
+	if timeout == 0 {
+		timeout = c.defaultJobTimeout
+	}
+	hasTimeout := timeout > 0
+	if job.TimeoutAtNextActivation && !next.IsZero() {
+		untilNext := next.Sub(c.clock.Now())
+		if !hasTimeout || untilNext < timeout {
+			timeout = untilNext
+			hasTimeout = true
+		}
+	}
+	if !hasTimeout {
+		return job.Run(ctx)
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := job.Run(runCtx)
+	if runCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		return &TimeoutError{Timeout: timeout, Err: err}
+	}
+	return err
+}
+
+// jobKey returns the etcd key of the job suffixed with the given name.
+func (c *Cron) jobKey(job Job, name string) string {
+	return fmt.Sprintf("%s/%s/%s", c.keyPrefix, job.canonicalName(), name)
+}
+
+// detachedContext returns a context to update etcd once an execution is over.
+// The execution context may be canceled at that point (ie. by its timeout or by
+// Shutdown), the update has to be done anyway: the context keeps the values of
+// ctx, but not its cancellation, and expires after the lock timeout.
+func (c *Cron) detachedContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), c.lockTimeout)
+}
+
+// keepWatching calls watch, which watches etcd until an error occurs, again and
+// again until ctx is done. The errors are given to the etcd errors handler.
+func (c *Cron) keepWatching(ctx context.Context, what string, watch func(context.Context, *etcdclient.Client) error) {
+	client, ok := c.etcdClient()
+	if !ok {
+		return
+	}
+
+	for ctx.Err() == nil {
+		err := watch(ctx, client)
+		if err != nil && ctx.Err() == nil {
+			go c.etcdErrorsHandler(ctx, Job{}, errors.Wrapf(err, "fail to watch %v", what))
+		}
+
+		timer := c.clock.NewTimer(watchRetryDelay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C():
+		}
+	}
+}
+
+// skipIteration reports a skipped iteration to the skipped iterations handler.
+func (c *Cron) skipIteration(ctx context.Context, job Job, reason error) {
+	if c.skipHandler != nil {
+		go c.skipHandler(ctx, job, reason)
+	}
+}
+
+type scheduledTimeKey struct{}
+
+// ScheduledTime returns the activation time of the iteration being executed
+// with the given context, without the jitter of the job. It returns the zero
+// time if ctx is not the context of a job execution.
+func ScheduledTime(ctx context.Context) time.Time {
+	t, _ := ctx.Value(scheduledTimeKey{}).(time.Time)
+	return t
+}
+
+// trackExecution registers a running execution of the job, so that Shutdown
+// is able to cancel it and to report it if it is still running. The returned
+// function must be called once the execution is over.
+func (c *Cron) trackExecution(ctx context.Context, job Job) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	exec := &execution{job: job, cancel: cancel}
+
+	c.executionsMutex.Lock()
+	defer c.executionsMutex.Unlock()
+	if c.runningExecutions == nil {
+		c.runningExecutions = map[*execution]struct{}{}
+	}
+	c.runningExecutions[exec] = struct{}{}
+	if c.shuttingDown && c.shutdownMode == ShutdownCancel {
+		cancel()
+	}
+
+	return ctx, func() {
+		c.executionsMutex.Lock()
+		defer c.executionsMutex.Unlock()
+		delete(c.runningExecutions, exec)
+		cancel()
+	}
+}
+
+// startExecution registers an execution which is not started by the scheduler
+// goroutine, so that Shutdown waits for it. It returns false once Shutdown has
+// been called, the execution must then not be started.
+func (c *Cron) startExecution() bool {
+	c.executionsMutex.Lock()
+	defer c.executionsMutex.Unlock()
+	if c.shuttingDown {
+		return false
+	}
+	c.executions.Add(1)
+	return true
+}
+
+// isShuttingDown returns true once Shutdown has been called.
+func (c *Cron) isShuttingDown() bool {
+	c.executionsMutex.Lock()
+	defer c.executionsMutex.Unlock()
+	return c.shuttingDown
+}
+
+// untilShutdown returns a context which is also canceled once Shutdown is
+// called, for the iterations waiting to start.
+func (c *Cron) untilShutdown(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-c.shutdown:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// Stop the cron scheduler. It does not stop any job already running, use
+// Shutdown to wait for them.
 func (c *Cron) Stop() {
 	c.stop <- struct{}{}
 	c.running = false
 }
 
+// Shutdown stops the cron scheduler, no new iteration is started, and waits
+// for the running executions to finish. With the ShutdownCancel mode, the
+// context of the running executions is canceled first.
+//
+// If ctx expires before every execution is finished, a *ShutdownError listing
+// the jobs still running is returned.
+func (c *Cron) Shutdown(ctx context.Context) error {
+	if c.running {
+		c.Stop()
+	}
+
+	c.executionsMutex.Lock()
+	if !c.shuttingDown {
+		c.shuttingDown = true
+		close(c.shutdown)
+	}
+	if c.shutdownMode == ShutdownCancel {
+		for exec := range c.runningExecutions {
+			exec.cancel()
+		}
+	}
+	c.executionsMutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		c.executions.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return &ShutdownError{RunningJobs: c.runningJobNames(), Err: ctx.Err()}
+	}
+}
+
+// Close stops the cron scheduler if it is running, and closes the etcd mutex
+// builder if it has been created by New. A builder given with
+// WithEtcdMutexBuilder has to be closed by the caller.
+func (c *Cron) Close() error {
+	if c.running {
+		c.Stop()
+	}
+	if !c.ownsEtcdClient {
+		return nil
+	}
+	closer, ok := c.etcdclient.(io.Closer)
+	if !ok {
+		return nil
+	}
+	return closer.Close()
+}
+
+// runningJobNames returns the sorted names of the jobs currently running.
+func (c *Cron) runningJobNames() []string {
+	c.executionsMutex.Lock()
+	defer c.executionsMutex.Unlock()
+
+	names := []string{}
+	for exec := range c.runningExecutions {
+		names = append(names, exec.job.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// findEntry returns the index and the entry of the job having the same
+// canonical name as the given name.
+func (c *Cron) findEntry(name string) (int, *Entry, error) {
+	canonicalName := Job{Name: name}.canonicalName()
+	for i, e := range c.entries {
+		if e.Job.canonicalName() == canonicalName {
+			return i, e, nil
+		}
+	}
+	return -1, nil, errors.Wrapf(ErrJobNotFound, "job '%v'", name)
+}
+
+// jobLocation returns the location in which the rhythm of the job is evaluated.
+func (c *Cron) jobLocation(job Job) *time.Location {
+	if job.Location != nil {
+		return job.Location
+	}
+	return c.location
+}
+
+// nextActivation returns the next activation time of the entry after t, in the
+// location of its job.
+func (c *Cron) nextActivation(entry *Entry, t time.Time) time.Time {
+	return entry.Schedule.Next(t.In(c.jobLocation(entry.Job)))
+}
+
+// prevActivation returns the previous activation time of the entry before t,
+// in the location of its job, or the zero time if its schedule is not a
+// PrevSchedule.
+func (c *Cron) prevActivation(entry *Entry, t time.Time) time.Time {
+	schedule, ok := entry.Schedule.(PrevSchedule)
+	if !ok {
+		return time.Time{}
+	}
+	return schedule.Prev(t.In(c.jobLocation(entry.Job)))
+}
+
+// addEntry appends the entry to the list of entries. If now is not the zero
+// time, the next and previous activation times of the entry are computed from
+// it.
+func (c *Cron) addEntry(entry *Entry, now time.Time) error {
+	if _, e, err := c.findEntry(entry.Job.Name); err == nil {
+		return errors.Wrapf(ErrJobAlreadyExists, "job '%v' conflicts with job '%v'", entry.Job.Name, e.Job.Name)
+	}
+	if !now.IsZero() {
+		entry.Next = c.nextActivation(entry, now)
+		entry.Prev = c.prevActivation(entry, now)
+	}
+	c.entries = append(c.entries, entry)
+	return nil
+}
+
+// replaceEntry replaces the entry having the same canonical name as the given
+// entry. If now is not the zero time, the next activation time of the entry is
+// computed from it.
+func (c *Cron) replaceEntry(entry *Entry, now time.Time) error {
+	i, e, err := c.findEntry(entry.Job.Name)
+	if err != nil {
+		return err
+	}
+	entry.Prev = e.Prev
+	if !now.IsZero() {
+		entry.Next = c.nextActivation(entry, now)
+		if entry.Prev.IsZero() {
+			entry.Prev = c.prevActivation(entry, now)
+		}
+	}
+	c.entries[i] = entry
+	return nil
+}
+
+// removeEntry removes the entry having the same canonical name as the given
+// name.
+func (c *Cron) removeEntry(name string) error {
+	i, _, err := c.findEntry(name)
+	if err != nil {
+		return err
+	}
+	c.entries = append(c.entries[:i], c.entries[i+1:]...)
+	return nil
+}
+
 // entrySnapshot returns a copy of the current cron entry list.
 func (c *Cron) entrySnapshot() []*Entry {
 	entries := []*Entry{}
@@ -309,6 +1117,7 @@ func (c *Cron) entrySnapshot() []*Entry {
 			Next:     e.Next,
 			Prev:     e.Prev,
 			Job:      e.Job,
+			Paused:   c.paused.get(e.Job),
 		})
 	}
 	return entries
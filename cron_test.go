@@ -2,10 +2,14 @@ package etcdcron
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"sync"
 	"testing"
 	"time"
+
+	etcdclient "go.etcd.io/etcd/client/v3"
 )
 
 // Many tests schedule a job for every second, and then wait at most a second
@@ -394,6 +398,739 @@ func TestCron_Parallel(t *testing.T) {
 	}
 }
 
+// Test that two jobs with the same canonical name can't be added.
+func TestAddJobAlreadyExists(t *testing.T) {
+	cron, err := New()
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	err = cron.AddJob(Job{Name: "Test Duplicate", Rhythm: "* * * * * ?", Func: func(context.Context) error { return nil }})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = cron.AddJob(Job{Name: "test-duplicate", Rhythm: "@hourly", Func: func(context.Context) error { return nil }})
+	if !errors.Is(err, ErrJobAlreadyExists) {
+		t.Fatalf("expected ErrJobAlreadyExists, got %v", err)
+	}
+
+	cron.Start(context.Background())
+	defer cron.Stop()
+
+	err = cron.Schedule(Every(time.Hour), Job{Name: "test_duplicate", Func: func(context.Context) error { return nil }})
+	if !errors.Is(err, ErrJobAlreadyExists) {
+		t.Fatalf("expected ErrJobAlreadyExists, got %v", err)
+	}
+	if len(cron.Entries()) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(cron.Entries()))
+	}
+}
+
+// Start cron, remove a job, expect it doesn't run anymore.
+func TestRemoveJob(t *testing.T) {
+	cron, err := New()
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	cron.AddJob(Job{Name: "test-remove-1", Rhythm: "0 0 0 1 1 ?", Func: func(context.Context) error { return nil }})
+	cron.AddJob(Job{Name: "test-remove-2", Rhythm: "0 0 0 31 12 ?", Func: func(context.Context) error { return nil }})
+
+	err = cron.RemoveJob("test-remove-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cron.Start(context.Background())
+	defer cron.Stop()
+
+	err = cron.RemoveJob("test-remove-2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = cron.RemoveJob("test-remove-2")
+	if !errors.Is(err, ErrJobNotFound) {
+		t.Fatalf("expected ErrJobNotFound, got %v", err)
+	}
+	if len(cron.Entries()) != 0 {
+		t.Fatalf("expected no entry, got %d", len(cron.Entries()))
+	}
+}
+
+// Start cron, replace a job, expect its schedule is updated.
+func TestReplaceJob(t *testing.T) {
+	cron, err := New()
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	cron.AddJob(Job{Name: "test-replace", Rhythm: "0 0 0 1 1 ?", Func: func(context.Context) error { return nil }})
+	cron.Start(context.Background())
+	defer cron.Stop()
+
+	err = cron.ReplaceJob(Job{Name: "test-replace", Rhythm: "@every 1h", Func: func(context.Context) error { return nil }})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	entries := cron.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+	if entries[0].Job.Rhythm != "@every 1h" {
+		t.Errorf("expected rhythm to be replaced, got %v", entries[0].Job.Rhythm)
+	}
+	if time.Until(entries[0].Next) > time.Hour {
+		t.Errorf("expected next activation within an hour, got %v", entries[0].Next)
+	}
+
+	err = cron.ReplaceJob(Job{Name: "test-replace-unknown", Rhythm: "@hourly", Func: func(context.Context) error { return nil }})
+	if !errors.Is(err, ErrJobNotFound) {
+		t.Fatalf("expected ErrJobNotFound, got %v", err)
+	}
+}
+
+// Test looking up jobs by name, whether cron is running or not.
+func TestJobLookup(t *testing.T) {
+	cron, err := New()
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	cron.AddJob(Job{Name: "test-lookup", Rhythm: "@hourly", Func: func(context.Context) error { return nil }})
+
+	job, err := cron.Job("test-lookup")
+	if err != nil || job.Rhythm != "@hourly" {
+		t.Fatalf("unexpected lookup result: %v, %v", job, err)
+	}
+
+	cron.Start(context.Background())
+	defer cron.Stop()
+
+	job, err = cron.Job("test-lookup")
+	if err != nil || job.Rhythm != "@hourly" {
+		t.Fatalf("unexpected lookup result: %v, %v", job, err)
+	}
+	_, err = cron.Job("test-lookup-unknown")
+	if !errors.Is(err, ErrJobNotFound) {
+		t.Fatalf("expected ErrJobNotFound, got %v", err)
+	}
+}
+
+// Test that Shutdown waits for the running executions.
+func TestShutdownWaitsForExecutions(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	finished := make(chan struct{})
+
+	cron, err := New(WithEtcdMutexBuilder(newMemoryMutexBuilder()))
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	cron.AddJob(Job{
+		Name:   "test-shutdown-wait",
+		Rhythm: "* * * * * ?",
+		Func: func(context.Context) error {
+			select {
+			case started <- struct{}{}:
+			default:
+				return nil
+			}
+			<-release
+			close(finished)
+			return nil
+		},
+	})
+	cron.Start(context.Background())
+
+	select {
+	case <-time.After(ONE_SECOND):
+		t.Fatal("job did not start")
+	case <-started:
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = cron.Shutdown(ctx)
+	var shutdownErr *ShutdownError
+	if !errors.As(err, &shutdownErr) {
+		t.Fatalf("expected a ShutdownError, got %v", err)
+	}
+	if len(shutdownErr.RunningJobs) != 1 || shutdownErr.RunningJobs[0] != "test-shutdown-wait" {
+		t.Errorf("unexpected running jobs: %v", shutdownErr.RunningJobs)
+	}
+
+	close(release)
+	err = cron.Shutdown(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	select {
+	case <-finished:
+	default:
+		t.Error("Shutdown returned before the job finished")
+	}
+}
+
+// Test that Shutdown cancels the running executions with ShutdownCancel.
+func TestShutdownCancelsExecutions(t *testing.T) {
+	started := make(chan struct{}, 1)
+
+	cron, err := New(
+		WithEtcdMutexBuilder(newMemoryMutexBuilder()),
+		WithShutdownMode(ShutdownCancel),
+	)
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	cron.AddJob(Job{
+		Name:   "test-shutdown-cancel",
+		Rhythm: "* * * * * ?",
+		Func: func(ctx context.Context) error {
+			started <- struct{}{}
+			<-ctx.Done()
+			return nil
+		},
+	})
+	cron.Start(context.Background())
+
+	select {
+	case <-time.After(ONE_SECOND):
+		t.Fatal("job did not start")
+	case <-started:
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ONE_SECOND)
+	defer cancel()
+	err = cron.Shutdown(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Test which entries fire at which scheduled time, using a fake clock.
+func TestFakeClockScheduling(t *testing.T) {
+	type run struct {
+		job       string
+		scheduled time.Time
+	}
+	runs := make(chan run, 10)
+	record := func(job string) func(context.Context) error {
+		return func(ctx context.Context) error {
+			runs <- run{job: job, scheduled: ScheduledTime(ctx)}
+			return nil
+		}
+	}
+
+	start := time.Date(2012, time.July, 9, 14, 45, 0, 0, time.Local)
+	clock := NewFakeClock(start)
+	cron, err := New(
+		WithClock(clock),
+		WithEtcdMutexBuilder(newMemoryMutexBuilder()),
+	)
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	cron.AddJob(Job{Name: "minutely", Rhythm: "0 * * * * ?", Func: record("minutely")})
+	cron.AddJob(Job{Name: "half-minutely", Rhythm: "@every 30s", Func: record("half-minutely")})
+	cron.AddJob(Job{Name: "yearly", Rhythm: "@yearly", Func: record("yearly")})
+	cron.Start(context.Background())
+	defer cron.Stop()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+
+	expecteds := map[run]bool{
+		{job: "half-minutely", scheduled: start.Add(30 * time.Second)}: true,
+		{job: "half-minutely", scheduled: start.Add(time.Minute)}:      true,
+		{job: "minutely", scheduled: start.Add(time.Minute)}:           true,
+	}
+	for len(expecteds) > 0 {
+		select {
+		case <-time.After(ONE_SECOND):
+			t.Fatal("expected job to run")
+		case r := <-runs:
+			if !expecteds[r] {
+				t.Fatalf("unexpected run of %v at %v", r.job, r.scheduled)
+			}
+			delete(expecteds, r)
+		}
+	}
+
+	entries := cron.Entries()
+	if entries[0].Job.Name != "half-minutely" || !entries[0].Next.Equal(start.Add(90*time.Second)) {
+		t.Errorf("unexpected first entry %v at %v", entries[0].Job.Name, entries[0].Next)
+	}
+	select {
+	case r := <-runs:
+		t.Errorf("unexpected run of %v at %v", r.job, r.scheduled)
+	default:
+	}
+}
+
+// Test that executions exceeding their timeout are canceled and reported.
+func TestJobTimeout(t *testing.T) {
+	tests := map[string]struct {
+		job  Job
+		opts []CronOpt
+	}{
+		"job timeout": {
+			job: Job{Timeout: 50 * time.Millisecond},
+		},
+		"default timeout": {
+			opts: []CronOpt{WithDefaultJobTimeout(50 * time.Millisecond)},
+		},
+		"job timeout overriding the default timeout": {
+			job:  Job{Timeout: 50 * time.Millisecond},
+			opts: []CronOpt{WithDefaultJobTimeout(time.Hour)},
+		},
+		"timeout at next activation": {
+			job: Job{TimeoutAtNextActivation: true},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			errs := make(chan error, 1)
+			clock := NewFakeClock(time.Date(2012, time.July, 9, 14, 45, 0, 0, time.Local))
+			opts := append([]CronOpt{
+				WithClock(clock),
+				WithEtcdMutexBuilder(newMemoryMutexBuilder()),
+				WithErrorsHandler(func(_ context.Context, _ Job, err error) { errs <- err }),
+			}, test.opts...)
+			cron, err := New(opts...)
+			if err != nil {
+				t.Fatal("unexpected error")
+			}
+
+			job := test.job
+			job.Name = "test-timeout"
+			job.Rhythm = "@every 1s"
+			job.Func = func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			cron.AddJob(job)
+			cron.Start(context.Background())
+			defer cron.Stop()
+
+			clock.BlockUntil(1)
+			clock.Advance(time.Second)
+
+			select {
+			case <-time.After(2 * ONE_SECOND):
+				t.Fatal("expected job to time out")
+			case err := <-errs:
+				var timeoutErr *TimeoutError
+				if !errors.As(err, &timeoutErr) {
+					t.Fatalf("expected a TimeoutError, got %v", err)
+				}
+				if !errors.Is(err, context.DeadlineExceeded) {
+					t.Errorf("expected the error of the job to be wrapped, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+// Test that Close closes the mutex builder created by New, but not the one
+// given as option.
+func TestClose(t *testing.T) {
+	cron, err := New()
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	cron.Start(context.Background())
+	err = cron.Close()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err = cron.etcdclient.NewMutex("etcd_cron/test-close/1")
+	if !errors.Is(err, ErrMutexBuilderClosed) {
+		t.Fatalf("expected ErrMutexBuilderClosed, got %v", err)
+	}
+
+	builder, err := NewEtcdMutexBuilder(etcdclient.Config{Endpoints: []string{defaultEtcdEndpoint}})
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	defer builder.(io.Closer).Close()
+	cron, err = New(WithEtcdMutexBuilder(builder))
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	err = cron.Close()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if builder.(*etcdMutexBuilder).closed {
+		t.Error("expected the builder given as option not to be closed")
+	}
+}
+
+// Test that two crons sharing the same mutex builder run each iteration once.
+func TestSharedMutexBuilder(t *testing.T) {
+	builder, err := NewEtcdMutexBuilder(etcdclient.Config{Endpoints: []string{defaultEtcdEndpoint}})
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	defer builder.(io.Closer).Close()
+
+	prefix := fmt.Sprintf("etcd_cron_test_shared_builder_%d", time.Now().UnixNano())
+	var mutex sync.Mutex
+	runs := map[int64]int{}
+	for i := 0; i < 2; i++ {
+		cron, err := New(WithEtcdMutexBuilder(builder), WithKeyPrefix(prefix))
+		if err != nil {
+			t.Fatal("unexpected error")
+		}
+		cron.AddJob(Job{
+			Name:   "test-shared-builder",
+			Rhythm: "* * * * * ?",
+			Func: func(ctx context.Context) error {
+				mutex.Lock()
+				defer mutex.Unlock()
+				runs[ScheduledTime(ctx).Unix()]++
+				return nil
+			},
+		})
+		cron.Start(context.Background())
+		defer cron.Stop()
+	}
+	client := builder.(etcdClientProvider).EtcdClient()
+	defer client.Delete(context.Background(), prefix+"/", etcdclient.WithPrefix())
+
+	time.Sleep(2 * ONE_SECOND)
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(runs) == 0 {
+		t.Fatal("expected the job to run")
+	}
+	for scheduled, count := range runs {
+		if count != 1 {
+			t.Errorf("iteration %v run %d times", time.Unix(scheduled, 0), count)
+		}
+	}
+}
+
+func TestNewInvalidOptions(t *testing.T) {
+	tests := map[string][]CronOpt{
+		"negative lock timeout": {WithLockTimeout(-time.Second)},
+		"zero lock timeout":     {WithLockTimeout(0)},
+		"empty key prefix":      {WithKeyPrefix("")},
+		"slash key prefix":      {WithKeyPrefix("/")},
+	}
+	for name, opts := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(append(opts, WithEtcdMutexBuilder(newMemoryMutexBuilder()))...)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+// Test that the lock keys are created under the configured prefix.
+func TestKeyPrefix(t *testing.T) {
+	done := make(chan struct{})
+	builder := newMemoryMutexBuilder()
+	clock := NewFakeClock(time.Date(2012, time.July, 9, 14, 45, 0, 0, time.Local))
+	cron, err := New(
+		WithClock(clock),
+		WithEtcdMutexBuilder(builder),
+		WithKeyPrefix("my_app/cron/"),
+	)
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	cron.AddJob(Job{
+		Name:   "Test Prefix",
+		Rhythm: "@every 1s",
+		Func:   func(context.Context) error { close(done); return nil },
+	})
+	cron.Start(context.Background())
+	defer cron.Stop()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	select {
+	case <-time.After(ONE_SECOND):
+		t.Fatal("expected job to run")
+	case <-done:
+	}
+
+	expected := fmt.Sprintf("my_app/cron/test_prefix/%d", clock.Now().Unix())
+	if keys := builder.mutexKeys(); len(keys) != 1 || keys[0] != expected {
+		t.Errorf("expected lock key %v, got %v", expected, keys)
+	}
+}
+
+// Test that the rhythms are evaluated in the location of the job, or of the
+// cron, whatever the location of the clock, so that the nodes of a cluster
+// compute the same activation times.
+func TestLocation(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := NewFakeClock(time.Date(2012, time.July, 9, 14, 45, 0, 0, time.UTC))
+	cron, err := New(
+		WithClock(clock),
+		WithEtcdMutexBuilder(newMemoryMutexBuilder()),
+		WithLocation(tokyo),
+	)
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	noop := func(context.Context) error { return nil }
+	cron.AddJob(Job{Name: "cron-location", Rhythm: "0 0 9 * * *", Func: noop})
+	cron.AddJob(Job{Name: "job-location", Rhythm: "0 0 9 * * *", Location: paris, Func: noop})
+	cron.AddJob(Job{Name: "rhythm-location", Rhythm: "CRON_TZ=UTC 0 0 9 * * *", Location: paris, Func: noop})
+	cron.Start(context.Background())
+	defer cron.Stop()
+	clock.BlockUntil(1)
+
+	expected := map[string]time.Time{
+		"cron-location":   time.Date(2012, time.July, 10, 0, 0, 0, 0, time.UTC),
+		"job-location":    time.Date(2012, time.July, 10, 7, 0, 0, 0, time.UTC),
+		"rhythm-location": time.Date(2012, time.July, 10, 9, 0, 0, 0, time.UTC),
+	}
+	for _, entry := range cron.Entries() {
+		if !entry.Next.Equal(expected[entry.Job.Name]) {
+			t.Errorf("%v: (expected) %v != %v (actual)", entry.Job.Name, expected[entry.Job.Name], entry.Next)
+		}
+	}
+}
+
+// Test that the entry of a schedule whose years are over is parked at the end
+// of the entries, with a zero next activation time.
+func TestYearsOver(t *testing.T) {
+	done := make(chan struct{})
+	clock := NewFakeClock(time.Date(2012, time.December, 31, 23, 59, 58, 0, time.UTC))
+	cron, err := New(
+		WithClock(clock),
+		WithEtcdMutexBuilder(newMemoryMutexBuilder()),
+		WithLocation(time.UTC),
+	)
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	cron.AddJob(Job{
+		Name:   "last-year",
+		Rhythm: "* * * * * ? 2012",
+		Func:   func(context.Context) error { close(done); return nil },
+	})
+	cron.AddJob(Job{Name: "yearly", Rhythm: "@yearly", Func: func(context.Context) error { return nil }})
+	cron.Start(context.Background())
+	defer cron.Stop()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	select {
+	case <-time.After(ONE_SECOND):
+		t.Fatal("expected job to run")
+	case <-done:
+	}
+
+	entries := cron.Entries()
+	if len(entries) != 2 || entries[1].Job.Name != "last-year" {
+		t.Fatalf("expected the job to be the last entry, got %v", entries)
+	}
+	if !entries[1].Next.IsZero() {
+		t.Errorf("expected no next activation, got %v", entries[1].Next)
+	}
+	if !entries[1].Prev.Equal(time.Date(2012, time.December, 31, 23, 59, 59, 0, time.UTC)) {
+		t.Errorf("unexpected previous activation %v", entries[1].Prev)
+	}
+}
+
+// Test that the entries give the previous activation of their schedule before
+// the jobs are run.
+func TestEntriesPrev(t *testing.T) {
+	clock := NewFakeClock(time.Date(2012, time.July, 9, 14, 45, 0, 0, time.UTC))
+	cron, err := New(
+		WithClock(clock),
+		WithEtcdMutexBuilder(newMemoryMutexBuilder()),
+		WithLocation(time.UTC),
+	)
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	cron.AddJob(Job{Name: "daily", Rhythm: "0 0 9 * * *", Func: func(context.Context) error { return nil }})
+	cron.Start(context.Background())
+	defer cron.Stop()
+	cron.AddJob(Job{Name: "every", Rhythm: "@every 1h", Func: func(context.Context) error { return nil }})
+
+	expected := map[string]time.Time{
+		"daily": time.Date(2012, time.July, 9, 9, 0, 0, 0, time.UTC),
+		"every": time.Date(2012, time.July, 9, 13, 45, 0, 0, time.UTC),
+	}
+	for _, entry := range cron.Entries() {
+		if !entry.Prev.Equal(expected[entry.Job.Name]) {
+			t.Errorf("%s: (expected) %v != %v (actual)", entry.Job.Name, expected[entry.Job.Name], entry.Prev)
+		}
+	}
+}
+
+// Test that the H tokens are resolved from the canonical name of the job, so
+// that the nodes compute the same activation times.
+func TestHashedRhythm(t *testing.T) {
+	clock := NewFakeClock(time.Date(2012, time.July, 9, 14, 45, 0, 0, time.UTC))
+	nexts := map[string]time.Time{}
+	for _, name := range []string{"Hashed Job", "hashed-job"} {
+		cron, err := New(WithClock(clock), WithEtcdMutexBuilder(newMemoryMutexBuilder()), WithLocation(time.UTC))
+		if err != nil {
+			t.Fatal("unexpected error")
+		}
+		err = cron.AddJob(Job{Name: name, Rhythm: "H H * * * *", Func: func(context.Context) error { return nil }})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		cron.Start(context.Background())
+		entries := cron.Entries()
+		cron.Stop()
+		nexts[name] = entries[0].Next
+	}
+
+	schedule, _ := defaultParser.WithHashKey("hashed_job").Parse("H H * * * *")
+	expected := schedule.Next(clock.Now().In(time.UTC))
+	for name, next := range nexts {
+		if !next.Equal(expected) {
+			t.Errorf("%v: (expected) %v != %v (actual)", name, expected, next)
+		}
+	}
+}
+
+func TestJitterDelay(t *testing.T) {
+	job := Job{Name: "Jitter Job", Jitter: time.Minute}
+	at := time.Date(2012, time.July, 9, 14, 45, 0, 0, time.UTC)
+
+	delays := map[time.Duration]bool{}
+	for i := 0; i < 100; i++ {
+		scheduledAt := at.Add(time.Duration(i) * time.Hour)
+		delay := jitterDelay(job, scheduledAt)
+		if delay < 0 || delay >= job.Jitter {
+			t.Errorf("%v: delay %v out of [0, %v)", scheduledAt, delay, job.Jitter)
+		}
+		// Same delay on all the nodes, whatever the location of their clock
+		if again := jitterDelay(Job{Name: "jitter_job", Jitter: time.Minute}, scheduledAt.Local()); again != delay {
+			t.Errorf("%v: (expected) %v != %v (actual)", scheduledAt, delay, again)
+		}
+		delays[delay] = true
+	}
+	if len(delays) < 50 {
+		t.Errorf("expected the delays to be spread out, got %d distinct delays", len(delays))
+	}
+
+	if delay := jitterDelay(Job{Name: "no-jitter"}, at); delay != 0 {
+		t.Errorf("expected no delay, got %v", delay)
+	}
+}
+
+// Test that the iterations are delayed by the jitter of the job, while their
+// lock key and scheduled time are not.
+func TestJitter(t *testing.T) {
+	scheduled := make(chan time.Time, 1)
+	builder := newMemoryMutexBuilder()
+	clock := NewFakeClock(time.Date(2012, time.July, 9, 14, 45, 0, 0, time.UTC))
+	cron, err := New(WithClock(clock), WithEtcdMutexBuilder(builder), WithLocation(time.UTC))
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	job := Job{
+		Name:   "test-jitter",
+		Rhythm: "0 * * * * *",
+		Jitter: 30 * time.Second,
+		Func: func(ctx context.Context) error {
+			scheduled <- ScheduledTime(ctx)
+			return nil
+		},
+	}
+	cron.AddJob(job)
+	cron.Start(context.Background())
+	defer cron.Stop()
+
+	activation := time.Date(2012, time.July, 9, 14, 46, 0, 0, time.UTC)
+	delay := jitterDelay(job, activation)
+	if delay == 0 {
+		t.Fatal("expected a delay")
+	}
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	// The scheduler and the delayed iteration are waiting
+	clock.BlockUntil(2)
+	select {
+	case <-scheduled:
+		t.Fatal("expected the iteration to be delayed")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	clock.Advance(delay)
+	select {
+	case <-time.After(ONE_SECOND):
+		t.Fatal("expected job to run")
+	case s := <-scheduled:
+		if !s.Equal(activation) {
+			t.Errorf("expected scheduled time %v, got %v", activation, s)
+		}
+	}
+	expected := fmt.Sprintf("etcd_cron/test_jitter/%d", activation.Unix())
+	if keys := builder.mutexKeys(); len(keys) != 1 || keys[0] != expected {
+		t.Errorf("expected lock key %v, got %v", expected, keys)
+	}
+}
+
+// Test that an iteration waiting out its jitter is not run once Shutdown has
+// been called, Shutdown does not wait for its delay.
+func TestJitterShutdown(t *testing.T) {
+	runs := make(chan struct{}, 1)
+	skipped := make(chan error, 1)
+	clock := NewFakeClock(time.Date(2012, time.July, 9, 14, 45, 0, 0, time.UTC))
+	cron, err := New(
+		WithClock(clock),
+		WithEtcdMutexBuilder(newMemoryMutexBuilder()),
+		WithSkippedIterationsHandler(func(_ context.Context, _ Job, err error) {
+			skipped <- err
+		}),
+	)
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	cron.AddJob(Job{
+		Name:   "test-jitter-shutdown",
+		Rhythm: "0 * * * * *",
+		Jitter: 30 * time.Second,
+		Func: func(context.Context) error {
+			runs <- struct{}{}
+			return nil
+		},
+	})
+	cron.Start(context.Background())
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	// The scheduler and the delayed iteration are waiting
+	clock.BlockUntil(2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), ONE_SECOND)
+	defer cancel()
+	err = cron.Shutdown(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	select {
+	case err := <-skipped:
+		if !errors.Is(err, ErrShuttingDown) {
+			t.Errorf("unexpected skip reason: %v", err)
+		}
+	case <-time.After(ONE_SECOND):
+		t.Error("expected the iteration to be skipped")
+	}
+	if len(runs) != 0 {
+		t.Error("expected the delayed iteration not to run")
+	}
+}
+
 func wait(wg *sync.WaitGroup) chan bool {
 	ch := make(chan bool)
 	go func() {
@@ -411,3 +1148,73 @@ func stop(cron *Cron) chan bool {
 	}()
 	return ch
 }
+
+// memoryMutexBuilder is an in-memory EtcdMutexBuilder, it allows testing the
+// scheduling without an etcd server.
+type memoryMutexBuilder struct {
+	mutex sync.Mutex
+	locks map[string]chan struct{}
+	// keys of the mutexes created by the builder
+	keys []string
+}
+
+func newMemoryMutexBuilder() *memoryMutexBuilder {
+	return &memoryMutexBuilder{locks: map[string]chan struct{}{}}
+}
+
+func (b *memoryMutexBuilder) NewMutex(pfx string) (DistributedMutex, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.keys = append(b.keys, pfx)
+	return &memoryMutex{builder: b, key: pfx}, nil
+}
+
+func (b *memoryMutexBuilder) mutexKeys() []string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return append([]string{}, b.keys...)
+}
+
+type memoryMutex struct {
+	builder *memoryMutexBuilder
+	key     string
+	owned   chan struct{}
+}
+
+func (m *memoryMutex) IsOwner() etcdclient.Cmp {
+	return etcdclient.Compare(etcdclient.CreateRevision(m.key), ">", 0)
+}
+
+func (m *memoryMutex) Key() string {
+	return m.key
+}
+
+func (m *memoryMutex) Lock(ctx context.Context) error {
+	for {
+		m.builder.mutex.Lock()
+		released, locked := m.builder.locks[m.key]
+		if !locked {
+			m.owned = make(chan struct{})
+			m.builder.locks[m.key] = m.owned
+			m.builder.mutex.Unlock()
+			return nil
+		}
+		m.builder.mutex.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-released:
+		}
+	}
+}
+
+func (m *memoryMutex) Unlock(ctx context.Context) error {
+	m.builder.mutex.Lock()
+	defer m.builder.mutex.Unlock()
+	if m.builder.locks[m.key] == m.owned {
+		delete(m.builder.locks, m.key)
+		close(m.owned)
+	}
+	return nil
+}
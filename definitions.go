@@ -0,0 +1,308 @@
+package etcdcron
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	etcdclient "go.etcd.io/etcd/client/v3"
+)
+
+var (
+	// ErrHandlerNotFound is given to the errors handler when a job definition
+	// references a handler which has not been registered.
+	ErrHandlerNotFound = errors.New("handler not found")
+	// ErrJobDefinitionsDisabled is returned when managing the job definitions of
+	// a Cron created without WithJobDefinitions.
+	ErrJobDefinitionsDisabled = errors.New("job definitions are disabled")
+)
+
+// JobDefinition is a job stored in etcd, shared by all the crons created with
+// WithJobDefinitions and the same prefix.
+type JobDefinition struct {
+	// Name of the job
+	Name string `json:"name"`
+	// Cron-formatted rhythm (ie. 0,10,30 1-5 0 * * *)
+	Rhythm string `json:"rhythm"`
+	// Disabled jobs are not scheduled
+	Enabled bool `json:"enabled"`
+	// Name of the handler registered with RegisterHandler (optional, Name by
+	// default)
+	Handler string `json:"handler,omitempty"`
+	// Parameters given to the handler (optional)
+	Parameters map[string]string `json:"parameters,omitempty"`
+}
+
+func (d JobDefinition) handlerName() string {
+	if d.Handler == "" {
+		return d.Name
+	}
+	return d.Handler
+}
+
+// JobHandler is the routine method of the jobs defined in etcd.
+type JobHandler func(ctx context.Context, parameters map[string]string) error
+
+// definitionEvent is sent by the definitions watcher to the scheduler
+// goroutine.
+type definitionEvent struct {
+	name       string
+	definition *JobDefinition // nil if the definition has been deleted
+}
+
+type handlers struct {
+	mutex    sync.Mutex
+	handlers map[string]JobHandler
+}
+
+// WithJobDefinitions enables the job definitions stored in etcd under the given
+// prefix: the cron watches them and schedules the enabled ones with the
+// handlers registered with RegisterHandler. The prefix must not overlap the key
+// prefix of the crons.
+func WithJobDefinitions(prefix string) CronOpt {
+	return CronOpt(func(cron *Cron) {
+		cron.definitionsPrefix = strings.TrimSuffix(prefix, "/")
+		cron.jobDefinitions = true
+	})
+}
+
+// RegisterHandler registers the handler of the job definitions referencing
+// the given name. Handlers should be registered before starting the cron.
+func (c *Cron) RegisterHandler(name string, handler JobHandler) {
+	c.handlers.mutex.Lock()
+	defer c.handlers.mutex.Unlock()
+	if c.handlers.handlers == nil {
+		c.handlers.handlers = map[string]JobHandler{}
+	}
+	c.handlers.handlers[name] = handler
+}
+
+func (c *Cron) handler(name string) (JobHandler, bool) {
+	c.handlers.mutex.Lock()
+	defer c.handlers.mutex.Unlock()
+	handler, ok := c.handlers.handlers[name]
+	return handler, ok
+}
+
+func (c *Cron) definitionKey(name string) string {
+	return c.definitionsPrefix + "/" + Job{Name: name}.canonicalName()
+}
+
+// validateDefinitionsPrefix returns an error if the definitions prefix is
+// invalid or overlaps the key prefix.
+func (c *Cron) validateDefinitionsPrefix() error {
+	if c.definitionsPrefix == "" {
+		return errors.New("invalid empty job definitions prefix")
+	}
+	definitions, keys := c.definitionsPrefix+"/", c.keyPrefix+"/"
+	if strings.HasPrefix(definitions, keys) || strings.HasPrefix(keys, definitions) {
+		return errors.Errorf("job definitions prefix '%v' overlaps key prefix '%v'", c.definitionsPrefix, c.keyPrefix)
+	}
+	return nil
+}
+
+// PutJobDefinition creates or updates the job definition in etcd. All the
+// crons watching the definitions apply it.
+func (c *Cron) PutJobDefinition(ctx context.Context, definition JobDefinition) error {
+	client, ok := c.etcdClient()
+	if !ok {
+		return ErrNoEtcdClient
+	}
+	if !c.jobDefinitions {
+		return ErrJobDefinitionsDisabled
+	}
+	if definition.Name == "" {
+		return errors.New("invalid job definition without name")
+	}
+	_, err := c.parseRhythm(Job{Name: definition.Name, Rhythm: definition.Rhythm})
+	if err != nil {
+		return errors.Wrapf(err, "invalid rhythm of job '%v'", definition.Name)
+	}
+
+	value, err := json.Marshal(definition)
+	if err != nil {
+		return errors.Wrap(err, "fail to encode job definition")
+	}
+	_, err = client.Put(ctx, c.definitionKey(definition.Name), string(value))
+	if err != nil {
+		return errors.Wrapf(err, "fail to put definition of job '%v'", definition.Name)
+	}
+	return nil
+}
+
+// DeleteJobDefinition deletes the job definition from etcd. All the crons
+// watching the definitions remove the job.
+func (c *Cron) DeleteJobDefinition(ctx context.Context, name string) error {
+	client, ok := c.etcdClient()
+	if !ok {
+		return ErrNoEtcdClient
+	}
+	if !c.jobDefinitions {
+		return ErrJobDefinitionsDisabled
+	}
+	resp, err := client.Delete(ctx, c.definitionKey(name))
+	if err != nil {
+		return errors.Wrapf(err, "fail to delete definition of job '%v'", name)
+	}
+	if resp.Deleted == 0 {
+		return errors.Wrapf(ErrJobNotFound, "job definition '%v'", name)
+	}
+	return nil
+}
+
+// watchJobDefinitions sends the job definitions stored in etcd, then their
+// changes, to the scheduler goroutine until ctx is done.
+func (c *Cron) watchJobDefinitions(ctx context.Context) {
+	if !c.jobDefinitions {
+		return
+	}
+	c.keepWatching(ctx, "job definitions", c.syncJobDefinitions)
+}
+
+// syncJobDefinitions loads all the job definitions, including the deleted
+// ones, then watches their changes until an error occurs.
+func (c *Cron) syncJobDefinitions(ctx context.Context, client *etcdclient.Client) error {
+	prefix := c.definitionsPrefix + "/"
+	resp, err := client.Get(ctx, prefix, etcdclient.WithPrefix())
+	if err != nil {
+		return errors.Wrap(err, "fail to get job definitions")
+	}
+
+	events := []definitionEvent{}
+	for _, kv := range resp.Kvs {
+		if event, ok := c.decodeDefinition(ctx, strings.TrimPrefix(string(kv.Key), prefix), kv.Value); ok {
+			events = append(events, event)
+		}
+	}
+	// Sent at once so that the definitions deleted in the meantime are removed.
+	if !c.sendDefinitionEvents(ctx, definitionEvent{}, events...) {
+		return nil
+	}
+
+	watch := client.Watch(etcdclient.WithRequireLeader(ctx), prefix,
+		etcdclient.WithPrefix(), etcdclient.WithRev(resp.Header.Revision+1))
+	for resp := range watch {
+		if err := resp.Err(); err != nil {
+			return err
+		}
+		for _, ev := range resp.Events {
+			name := strings.TrimPrefix(string(ev.Kv.Key), prefix)
+			if ev.Type == etcdclient.EventTypeDelete {
+				if !strings.Contains(name, "/") && !c.sendDefinitionEvents(ctx, definitionEvent{name: name}) {
+					return nil
+				}
+				continue
+			}
+			event, ok := c.decodeDefinition(ctx, name, ev.Kv.Value)
+			if ok && !c.sendDefinitionEvents(ctx, event) {
+				return nil
+			}
+		}
+	}
+	if ctx.Err() == nil {
+		return errors.New("watch closed")
+	}
+	return nil
+}
+
+// decodeDefinition returns the definition event of the given key, relative to
+// the definitions prefix. Invalid definitions are given to the errors handler.
+func (c *Cron) decodeDefinition(ctx context.Context, name string, value []byte) (definitionEvent, bool) {
+	// Keys which are not job definitions, written under the prefix by another
+	// application
+	if strings.Contains(name, "/") {
+		return definitionEvent{}, false
+	}
+	var definition JobDefinition
+	err := json.Unmarshal(value, &definition)
+	if err != nil {
+		go c.errorsHandler(ctx, Job{Name: name}, errors.Wrapf(err, "invalid definition of job '%v'", name))
+		return definitionEvent{}, false
+	}
+	return definitionEvent{name: name, definition: &definition}, true
+}
+
+// sendDefinitionEvents sends the events to the scheduler goroutine. If the
+// first event is empty, the jobs defined in etcd which are not part of the
+// following events are removed. It returns false if ctx is done.
+func (c *Cron) sendDefinitionEvents(ctx context.Context, first definitionEvent, events ...definitionEvent) bool {
+	select {
+	case c.definitions <- append([]definitionEvent{first}, events...):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// applyDefinitionEvents adds, replaces or removes the entries of the jobs
+// defined in etcd. It's called by the scheduler goroutine.
+func (c *Cron) applyDefinitionEvents(ctx context.Context, events []definitionEvent, now time.Time) {
+	if events[0] == (definitionEvent{}) {
+		// Full synchronization: remove the jobs which are not defined anymore.
+		defined := map[string]bool{}
+		for _, event := range events[1:] {
+			defined[event.name] = true
+		}
+		for name := range c.definedJobs {
+			if !defined[name] {
+				c.applyDefinitionEvent(ctx, definitionEvent{name: name}, now)
+			}
+		}
+		events = events[1:]
+	}
+	for _, event := range events {
+		c.applyDefinitionEvent(ctx, event, now)
+	}
+}
+
+func (c *Cron) applyDefinitionEvent(ctx context.Context, event definitionEvent, now time.Time) {
+	if c.definedJobs == nil {
+		c.definedJobs = map[string]bool{}
+	}
+
+	if event.definition == nil || !event.definition.Enabled {
+		if c.definedJobs[event.name] {
+			c.removeEntry(event.name)
+			delete(c.definedJobs, event.name)
+		}
+		return
+	}
+
+	definition := *event.definition
+	job := Job{Name: definition.Name, Rhythm: definition.Rhythm}
+	if job.canonicalName() != event.name {
+		go c.errorsHandler(ctx, job, errors.Errorf("job definition '%v' stored with the name of another job '%v'", job.Name, event.name))
+		return
+	}
+	handler, ok := c.handler(definition.handlerName())
+	if !ok {
+		go c.errorsHandler(ctx, job, errors.Wrapf(ErrHandlerNotFound, "handler '%v' of job '%v'", definition.handlerName(), job.Name))
+		return
+	}
+	job.Func = func(ctx context.Context) error {
+		return handler(ctx, definition.Parameters)
+	}
+	schedule, err := c.parseRhythm(job)
+	if err != nil {
+		go c.errorsHandler(ctx, job, errors.Wrapf(err, "invalid rhythm of job '%v'", job.Name))
+		return
+	}
+
+	entry := &Entry{Schedule: schedule, Job: job}
+	// The entry is replaced only if it has been added from its definition, an
+	// entry of the same name added with AddJob is never replaced.
+	_, _, err = c.findEntry(event.name)
+	if err == nil && c.definedJobs[event.name] {
+		err = c.replaceEntry(entry, now)
+	} else {
+		err = c.addEntry(entry, now)
+	}
+	if err != nil {
+		go c.errorsHandler(ctx, job, err)
+		return
+	}
+	c.definedJobs[event.name] = true
+}
@@ -0,0 +1,185 @@
+package etcdcron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	etcdclient "go.etcd.io/etcd/client/v3"
+)
+
+// Test that the definition events add, replace and remove the jobs defined in
+// etcd, and leave the other jobs untouched.
+func TestApplyDefinitionEvents(t *testing.T) {
+	errs := make(chan error, 10)
+	cron, err := New(
+		WithEtcdMutexBuilder(newMemoryMutexBuilder()),
+		WithErrorsHandler(func(_ context.Context, _ Job, err error) {
+			errs <- err
+		}),
+	)
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	cron.RegisterHandler("report", func(context.Context, map[string]string) error { return nil })
+	cron.AddJob(Job{Name: "static", Rhythm: "@every 1s", Func: func(context.Context) error { return nil }})
+
+	definition := func(name, rhythm string, enabled bool) definitionEvent {
+		return definitionEvent{name: name, definition: &JobDefinition{Name: name, Rhythm: rhythm, Enabled: enabled, Handler: "report"}}
+	}
+	now := time.Now()
+
+	tests := []struct {
+		events   []definitionEvent
+		expected map[string]string
+		err      bool
+	}{
+		// Initial synchronization
+		{
+			[]definitionEvent{{}, definition("daily", "@daily", true), definition("hourly", "@hourly", false)},
+			map[string]string{"static": "@every 1s", "daily": "@daily"},
+			false,
+		},
+		// Update and enable
+		{
+			[]definitionEvent{definition("daily", "@weekly", true), definition("hourly", "@hourly", true)},
+			map[string]string{"static": "@every 1s", "daily": "@weekly", "hourly": "@hourly"},
+			false,
+		},
+		// Disable
+		{
+			[]definitionEvent{definition("daily", "@weekly", false)},
+			map[string]string{"static": "@every 1s", "hourly": "@hourly"},
+			false,
+		},
+		// A definition can't replace a job added with AddJob
+		{
+			[]definitionEvent{definition("static", "@daily", true)},
+			map[string]string{"static": "@every 1s", "hourly": "@hourly"},
+			true,
+		},
+		// Invalid rhythm
+		{
+			[]definitionEvent{definition("invalid", "bogus", true)},
+			map[string]string{"static": "@every 1s", "hourly": "@hourly"},
+			true,
+		},
+		// Unknown handler
+		{
+			[]definitionEvent{{name: "unknown", definition: &JobDefinition{Name: "unknown", Rhythm: "@daily", Enabled: true}}},
+			map[string]string{"static": "@every 1s", "hourly": "@hourly"},
+			true,
+		},
+		// Deletion
+		{
+			[]definitionEvent{{name: "hourly"}},
+			map[string]string{"static": "@every 1s"},
+			false,
+		},
+		// Synchronization after deletions
+		{
+			[]definitionEvent{definition("daily", "@daily", true), {}},
+			map[string]string{"static": "@every 1s", "daily": "@daily"},
+			false,
+		},
+		{
+			[]definitionEvent{{}},
+			map[string]string{"static": "@every 1s"},
+			false,
+		},
+	}
+
+	for i, test := range tests {
+		cron.applyDefinitionEvents(context.Background(), test.events, now)
+
+		select {
+		case err := <-errs:
+			if !test.err {
+				t.Errorf("%d: unexpected error: %v", i, err)
+			}
+		case <-time.After(50 * time.Millisecond):
+			if test.err {
+				t.Errorf("%d: expected an error", i)
+			}
+		}
+
+		actual := map[string]string{}
+		for _, entry := range cron.Entries() {
+			actual[entry.Job.Name] = entry.Job.Rhythm
+		}
+		if fmt.Sprint(actual) != fmt.Sprint(test.expected) {
+			t.Errorf("%d: (expected) %v != %v (actual)", i, test.expected, actual)
+		}
+	}
+}
+
+func TestNewJobDefinitionsInvalidPrefix(t *testing.T) {
+	tests := []string{"", "/", "etcd_cron", "etcd_cron/definitions", "etcd"}
+	for _, prefix := range tests[:4] {
+		_, err := New(WithEtcdMutexBuilder(newMemoryMutexBuilder()), WithJobDefinitions(prefix))
+		if err == nil {
+			t.Errorf("%q: expected an error", prefix)
+		}
+	}
+	// Valid prefix, but the mutex builder does not give access to etcd
+	_, err := New(WithEtcdMutexBuilder(newMemoryMutexBuilder()), WithJobDefinitions(tests[4]))
+	if !errors.Is(err, ErrNoEtcdClient) {
+		t.Errorf("expected ErrNoEtcdClient, got %v", err)
+	}
+}
+
+// Test that a job definition put in etcd is scheduled with its handler and
+// parameters, and removed once deleted.
+func TestJobDefinitions(t *testing.T) {
+	suffix := time.Now().UnixNano()
+	keyPrefix := fmt.Sprintf("etcd_cron_test_definitions_%d", suffix)
+	definitionsPrefix := fmt.Sprintf("etcd_cron_test_definitions_%d_jobs", suffix)
+	params := make(chan map[string]string, 10)
+	cron, err := New(WithKeyPrefix(keyPrefix), WithJobDefinitions(definitionsPrefix))
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	defer cron.Close()
+	client, _ := cron.etcdClient()
+	defer client.Delete(context.Background(), keyPrefix+"/", etcdclient.WithPrefix())
+	defer client.Delete(context.Background(), definitionsPrefix+"/", etcdclient.WithPrefix())
+
+	cron.RegisterHandler("report", func(_ context.Context, parameters map[string]string) error {
+		params <- parameters
+		return nil
+	})
+	cron.Start(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), ONE_SECOND)
+	defer cancel()
+	err = cron.PutJobDefinition(ctx, JobDefinition{
+		Name: "test-definition", Rhythm: "* * * * * ?", Enabled: true,
+		Handler: "report", Parameters: map[string]string{"env": "test"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case <-time.After(2 * ONE_SECOND):
+		t.Fatal("expected job to run")
+	case p := <-params:
+		if p["env"] != "test" {
+			t.Errorf("unexpected parameters %v", p)
+		}
+	}
+
+	err = cron.DeleteJobDefinition(ctx, "test-definition")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Wait for the deletion to be applied
+	for i := 0; len(cron.Entries()) != 0; i++ {
+		if i == 100 {
+			t.Fatal("expected job to be removed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
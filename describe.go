@@ -0,0 +1,388 @@
+package etcdcron
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Describer describes the schedules in a human language. EnglishDescriber is
+// used by default, other languages are supported by implementing it.
+type Describer interface {
+	DescribeSpec(s *SpecSchedule) string
+	DescribeConstantDelay(s ConstantDelaySchedule) string
+}
+
+// Describe returns the description in English of the given spec, ie. "every 15
+// minutes between 09:00 and 17:59, Monday through Friday". It returns a
+// *ParseError if the spec is not valid. The specs having H tokens are described
+// by a parser having a hash key, see Parser.Describe.
+func Describe(spec string) (string, error) {
+	return defaultParser.Describe(spec)
+}
+
+// DescribeWith returns the description of the given spec by the describer. It
+// returns a *ParseError if the spec is not valid.
+func DescribeWith(spec string, describer Describer) (string, error) {
+	return defaultParser.DescribeWith(spec, describer)
+}
+
+// Describe returns the description in English of the given spec, in the
+// dialect of the parser. The H tokens are resolved from the hash key of the
+// parser, ie. "at 04:37" for "0 H H * * *": the schedule of a job of the cron,
+// given by Entries, describes its actual activation times.
+func (p Parser) Describe(spec string) (string, error) {
+	return p.DescribeWith(spec, EnglishDescriber{})
+}
+
+// DescribeWith returns the description of the given spec, in the dialect of the
+// parser, by the describer.
+func (p Parser) DescribeWith(spec string, describer Describer) (string, error) {
+	schedule, err := p.Parse(spec)
+	if err != nil {
+		return "", err
+	}
+	switch s := schedule.(type) {
+	case *SpecSchedule:
+		return describer.DescribeSpec(s), nil
+	case ConstantDelaySchedule:
+		return describer.DescribeConstantDelay(s), nil
+	}
+	return "", errors.Errorf("schedule %T can't be described", schedule)
+}
+
+// Describe returns the description of the schedule in English.
+func (s *SpecSchedule) Describe() string {
+	return EnglishDescriber{}.DescribeSpec(s)
+}
+
+// Describe returns the description of the schedule in English.
+func (schedule ConstantDelaySchedule) Describe() string {
+	return EnglishDescriber{}.DescribeConstantDelay(schedule)
+}
+
+// EnglishDescriber describes the schedules in English.
+type EnglishDescriber struct{}
+
+var (
+	weekdayNames = []string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}
+	ordinals     = []string{"first", "second", "third", "fourth", "fifth"}
+)
+
+// DescribeConstantDelay returns the description of the schedule, ie. "every 5
+// minutes".
+func (EnglishDescriber) DescribeConstantDelay(s ConstantDelaySchedule) string {
+	for _, unit := range []struct {
+		name     string
+		duration time.Duration
+	}{{"hour", time.Hour}, {"minute", time.Minute}, {"second", time.Second}} {
+		if s.Delay%unit.duration == 0 {
+			return "every " + plural(int(s.Delay/unit.duration), unit.name)
+		}
+	}
+	return "every " + s.Delay.String()
+}
+
+// DescribeSpec returns the description of the schedule, made of its time of
+// the day, days, months and years, ie. "at 09:30, on the last day of the
+// month, in January".
+func (d EnglishDescriber) DescribeSpec(s *SpecSchedule) string {
+	parts := []string{d.describeTime(s)}
+	if days := d.describeDays(s); days != "" {
+		parts = append(parts, days)
+	}
+	if months := newFieldSet(s.Month, months); !months.all {
+		parts = append(parts, "in "+months.list(func(v uint) string { return time.Month(v).String() }))
+	}
+	if s.Year != nil {
+		parts = append(parts, "in "+yearSet(s.Year).list(func(v uint) string { return fmt.Sprint(v) }))
+	}
+	description := strings.Join(parts, ", ")
+	if s.Location != nil {
+		description += fmt.Sprintf(" (%v time)", s.Location)
+	}
+	return description
+}
+
+// describeTime describes the seconds, minutes and hours of the schedule. The
+// smallest field which is not 0 leads the description, the larger fields
+// restrict it.
+func (EnglishDescriber) describeTime(s *SpecSchedule) string {
+	seconds := newFieldSet(s.Second, seconds)
+	minutes := newFieldSet(s.Minute, minutes)
+	hours := newFieldSet(s.Hour, hours)
+
+	// A few times of the day are listed.
+	if !seconds.all && !minutes.all && !hours.all &&
+		len(seconds.values)*len(minutes.values)*len(hours.values) <= 4 {
+		times := []string{}
+		for _, h := range hours.values {
+			for _, m := range minutes.values {
+				for _, sec := range seconds.values {
+					times = append(times, clockTime(h, m, sec))
+				}
+			}
+		}
+		return "at " + joinList(times, "and")
+	}
+
+	var phrases []string
+	// Whether the last phrase is about specific values of its field, which is
+	// then repeated by the next field
+	pinned := false
+	switch {
+	case !seconds.is(0):
+		phrase, isPinned := seconds.lead("second")
+		phrases, pinned = append(phrases, phrase), isPinned
+		phrases, pinned = appendQualifier(phrases, pinned, minutes, "minute")
+	case !minutes.is(0):
+		phrase, isPinned := minutes.lead("minute")
+		phrases, pinned = append(phrases, phrase), isPinned
+	default:
+		// Every hour, the seconds and minutes being 0
+		switch step, ok := hours.step(); {
+		case hours.all:
+			return "every hour"
+		case ok:
+			phrase := "every " + plural(int(step), "hour")
+			if hours.values[0] != 0 {
+				phrase += " starting at " + clockTime(hours.values[0], 0, 0)
+			}
+			return phrase
+		case len(hours.runs) == 1:
+			run := hours.runs[0]
+			return fmt.Sprintf("every hour between %v and %v", clockTime(run[0], 0, 0), clockTime(run[1], 0, 0))
+		}
+		times := make([]string, 0, len(hours.values))
+		for _, h := range hours.values {
+			times = append(times, clockTime(h, 0, 0))
+		}
+		return "at " + joinList(times, "and")
+	}
+
+	switch step, ok := hours.step(); {
+	case hours.all:
+		if pinned {
+			phrases = append(phrases, "of every hour")
+		}
+	case len(hours.runs) == 1:
+		run := hours.runs[0]
+		phrases = append(phrases, fmt.Sprintf("between %v and %02d:59", clockTime(run[0], 0, 0), run[1]))
+	case ok:
+		phrases = append(phrases, "every "+plural(int(step), "hour"))
+	default:
+		phrases = append(phrases, "of hours "+hours.list(func(v uint) string { return fmt.Sprint(v) }))
+	}
+	return strings.Join(phrases, " ")
+}
+
+// appendQualifier appends the phrase restricting the previous phrases to the
+// values of the field.
+func appendQualifier(phrases []string, pinned bool, field fieldSet, unit string) ([]string, bool) {
+	if field.all {
+		if pinned {
+			phrases = append(phrases, "of every "+unit)
+		}
+		return phrases, false
+	}
+	if step, ok := field.step(); ok {
+		return append(phrases, "every "+plural(int(step), unit)), false
+	}
+	return append(phrases, "of "+field.valuesOf(unit)), true
+}
+
+// describeDays describes the days of month and days of week of the schedule.
+func (EnglishDescriber) describeDays(s *SpecSchedule) string {
+	var phrases []string
+	domSet := newFieldSet(s.Dom&^starBit, dom)
+	var domItems []string
+	if !domSet.all && len(domSet.values) > 0 {
+		if step, ok := domSet.step(); ok {
+			phrase := "every " + plural(int(step), "day")
+			if domSet.values[0] != dom.min {
+				phrase += fmt.Sprintf(" starting on day %d", domSet.values[0])
+			}
+			phrases = append(phrases, phrase)
+		} else {
+			domItems = append(domItems, domSet.valuesOf("day"))
+		}
+	}
+	if s.LastDay {
+		domItems = append(domItems, "the last day")
+	}
+	if s.LastWeekday {
+		domItems = append(domItems, "the last weekday")
+	}
+	nearest := newFieldSet(s.NearestWeekday, dom)
+	for _, day := range nearest.values {
+		domItems = append(domItems, fmt.Sprintf("the weekday nearest to day %d", day))
+	}
+
+	dowSet := newFieldSet(s.Dow&^starBit, dow)
+	var dowItems []string
+	if !dowSet.all && len(dowSet.values) > 0 {
+		weekdays := dowSet.list(func(v uint) string { return weekdayNames[v] })
+		if len(dowSet.runs) == 1 && dowSet.runs[0][1]-dowSet.runs[0][0] >= 2 {
+			dowItems = append(dowItems, weekdays)
+		} else {
+			dowItems = append(dowItems, "on "+weekdays)
+		}
+	}
+	var monthly []string
+	for _, day := range newFieldSet(s.LastDow, dow).values {
+		monthly = append(monthly, "the last "+weekdayNames[day])
+	}
+	for i := uint(0); i < 7*maxNthDow; i++ {
+		if 1<<i&s.NthDow > 0 {
+			monthly = append(monthly, fmt.Sprintf("the %v %v", ordinals[i/7], weekdayNames[i%7]))
+		}
+	}
+	if len(monthly) > 0 {
+		dowItems = append(dowItems, "on "+joinList(monthly, "or")+" of the month")
+	}
+
+	if len(domItems) > 0 {
+		phrases = append(phrases, "on "+joinList(domItems, "or")+" of the month")
+	}
+	if len(dowItems) > 0 {
+		phrases = append(phrases, joinList(dowItems, "or"))
+	}
+	if s.Dom&starBit > 0 || s.Dow&starBit > 0 {
+		// Both the day of month and the day of week must match.
+		return strings.Join(phrases, ", ")
+	}
+	return strings.Join(phrases, " or ")
+}
+
+// fieldSet is the analysis of the values of a field.
+type fieldSet struct {
+	values []uint
+	// Runs of consecutive values, as [first, last] pairs
+	runs [][2]uint
+	// Whether the set has all the values of the field
+	all    bool
+	bounds bounds
+}
+
+func newFieldSet(bits uint64, r bounds) fieldSet {
+	set := fieldSet{bounds: r}
+	for v := r.min; v <= r.max; v++ {
+		if 1<<v&bits > 0 {
+			set.add(v)
+		}
+	}
+	set.all = len(set.values) == int(r.max-r.min+1)
+	return set
+}
+
+// yearSet returns the analysis of the years of a schedule.
+func yearSet(bits []uint64) fieldSet {
+	set := fieldSet{bounds: years}
+	for year := years.min; year <= years.max; year++ {
+		i := year - years.min
+		if int(i/64) < len(bits) && bits[i/64]&(1<<(i%64)) > 0 {
+			set.add(year)
+		}
+	}
+	return set
+}
+
+func (f *fieldSet) add(v uint) {
+	f.values = append(f.values, v)
+	if n := len(f.runs); n > 0 && f.runs[n-1][1] == v-1 {
+		f.runs[n-1][1] = v
+	} else {
+		f.runs = append(f.runs, [2]uint{v, v})
+	}
+}
+
+func (f fieldSet) is(v uint) bool {
+	return len(f.values) == 1 && f.values[0] == v
+}
+
+// step returns the step of the set if it's made of values separated by the
+// same step, up to the end of the field, ie. "*/15" or "5/10".
+func (f fieldSet) step() (uint, bool) {
+	if len(f.values) < 2 {
+		return 0, false
+	}
+	step := f.values[1] - f.values[0]
+	if step < 2 {
+		return 0, false
+	}
+	for i := 2; i < len(f.values); i++ {
+		if f.values[i]-f.values[i-1] != step {
+			return 0, false
+		}
+	}
+	if f.values[len(f.values)-1]+step <= f.bounds.max {
+		return 0, false
+	}
+	return step, true
+}
+
+// lead returns the phrase describing the activations given by the field, and
+// whether it is about specific values of the field.
+func (f fieldSet) lead(unit string) (string, bool) {
+	if f.all {
+		return "every " + unit, false
+	}
+	if step, ok := f.step(); ok {
+		phrase := "every " + plural(int(step), unit)
+		if f.values[0] != f.bounds.min {
+			phrase += fmt.Sprintf(" starting at %v %d", unit, f.values[0])
+		}
+		return phrase, false
+	}
+	return "at " + f.valuesOf(unit), true
+}
+
+// valuesOf returns the values of the set with their unit, ie. "minute 5" or
+// "minutes 0 through 10".
+func (f fieldSet) valuesOf(unit string) string {
+	if len(f.values) > 1 {
+		unit += "s"
+	}
+	return unit + " " + f.list(func(v uint) string { return fmt.Sprint(v) })
+}
+
+// list returns the list of the values of the set, the runs of at least 3
+// values being given as ranges.
+func (f fieldSet) list(name func(uint) string) string {
+	items := []string{}
+	for _, run := range f.runs {
+		switch {
+		case run[1]-run[0] >= 2:
+			items = append(items, name(run[0])+" through "+name(run[1]))
+		case run[1] > run[0]:
+			items = append(items, name(run[0]), name(run[1]))
+		default:
+			items = append(items, name(run[0]))
+		}
+	}
+	return joinList(items, "and")
+}
+
+// joinList joins the items with commas, the last one with the conjunction.
+func joinList(items []string, conjunction string) string {
+	if len(items) < 2 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " " + conjunction + " " + items[len(items)-1]
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+func clockTime(hour, minute, second uint) string {
+	if second != 0 {
+		return fmt.Sprintf("%02d:%02d:%02d", hour, minute, second)
+	}
+	return fmt.Sprintf("%02d:%02d", hour, minute)
+}
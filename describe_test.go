@@ -0,0 +1,128 @@
+package etcdcron
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDescribe(t *testing.T) {
+	entries := []struct {
+		spec, expected string
+	}{
+		// Time of the day
+		{"* * * * * *", "every second"},
+		{"*/10 * * * * *", "every 10 seconds"},
+		{"30 * * * * *", "at second 30 of every minute"},
+		{"30 5 * * * *", "at second 30 of minute 5 of every hour"},
+		{"0 * * * * *", "every minute"},
+		{"0 5/15 * * * *", "every 15 minutes starting at minute 5"},
+		{"0 30 * * * *", "at minute 30 of every hour"},
+		{"0 0,30 9,12,18 * * *", "every 30 minutes of hours 9, 12 and 18"},
+		{"0 0 * * * *", "every hour"},
+		{"0 0 */2 * * *", "every 2 hours"},
+		{"0 0 1/3 * * *", "every 3 hours starting at 01:00"},
+		{"0 0 1/6 * * *", "at 01:00, 07:00, 13:00 and 19:00"},
+		{"0 0 9-17 * * *", "every hour between 09:00 and 17:00"},
+		{"0 30 9 * * *", "at 09:30"},
+		{"15 30 9 * * *", "at 09:30:15"},
+		{"0 0 9,17 * * *", "at 09:00 and 17:00"},
+
+		// Days, months and years
+		{"0 */15 9-17 * * mon-fri", "every 15 minutes between 09:00 and 17:59, Monday through Friday"},
+		{"0 0 12 * * mon,wed,fri", "at 12:00, on Monday, Wednesday and Friday"},
+		{"0 0 0 */2 * *", "at 00:00, every 2 days"},
+		{"0 0 0 1,15 * Mon", "at 00:00, on days 1 and 15 of the month or on Monday"},
+		{"0 0 0 L * ?", "at 00:00, on the last day of the month"},
+		{"0 0 0 LW * ?", "at 00:00, on the last weekday of the month"},
+		{"0 0 0 15W * ?", "at 00:00, on the weekday nearest to day 15 of the month"},
+		{"0 0 0 1,L * ?", "at 00:00, on day 1 or the last day of the month"},
+		{"0 0 0 ? * FriL", "at 00:00, on the last Friday of the month"},
+		{"0 0 0 ? * 2#2", "at 00:00, on the second Tuesday of the month"},
+		{"0 0 0 * Jan-Mar,Jul ?", "at 00:00, in January through March and July"},
+		{"0 0 0 1 Jan ? 2027", "at 00:00, on day 1 of the month, in January, in 2027"},
+		{"0 0 0 * * ? 2027-2030", "at 00:00, in 2027 through 2030"},
+		{"CRON_TZ=Europe/Paris 0 0 9 * * *", "at 09:00 (Europe/Paris time)"},
+
+		// Descriptors
+		{"@daily", "at 00:00"},
+		{"@weekly", "at 00:00, on Sunday"},
+		{"@yearly", "at 00:00, on day 1 of the month, in January"},
+		{"@every 5m", "every 5 minutes"},
+		{"@every 1h30m", "every 90 minutes"},
+		{"@every 1h", "every hour"},
+		{"@every 90s", "every 90 seconds"},
+	}
+
+	for _, c := range entries {
+		actual, err := Describe(c.spec)
+		if err != nil {
+			t.Errorf("%s => unexpected error %v", c.spec, err)
+			continue
+		}
+		if actual != c.expected {
+			t.Errorf("%s => (expected) %q != %q (actual)", c.spec, c.expected, actual)
+		}
+	}
+
+	_, err := Describe("0 60 * * *")
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Errorf("expected a *ParseError, got %v", err)
+	}
+}
+
+type testDescriber struct{}
+
+func (testDescriber) DescribeSpec(s *SpecSchedule) string {
+	return "spec"
+}
+
+func (testDescriber) DescribeConstantDelay(s ConstantDelaySchedule) string {
+	return "toutes les " + s.Delay.String()
+}
+
+func TestDescribeWith(t *testing.T) {
+	actual, err := DescribeWith("@every 5m", testDescriber{})
+	if err != nil || actual != "toutes les 5m0s" {
+		t.Errorf("unexpected description %q, %v", actual, err)
+	}
+	actual, err = DescribeWith("@daily", testDescriber{})
+	if err != nil || actual != "spec" {
+		t.Errorf("unexpected description %q, %v", actual, err)
+	}
+
+	if actual := Every(2 * time.Hour).Describe(); actual != "every 2 hours" {
+		t.Errorf("unexpected description %q", actual)
+	}
+}
+
+// Test that the H tokens are described by a parser having a hash key, as they
+// are resolved for the jobs.
+func TestParserDescribeHashed(t *testing.T) {
+	entries := []struct {
+		spec, expected string
+	}{
+		{"H H * * * *", "at second 53 of minute 13 of every hour"},
+		{"0 H H * * *", "at 11:13"},
+		{"0 H/15 * * * *", "every 15 minutes starting at minute 13"},
+	}
+
+	parser := defaultParser.WithHashKey("job")
+	for _, c := range entries {
+		actual, err := parser.Describe(c.spec)
+		if err != nil {
+			t.Errorf("%s => unexpected error %v", c.spec, err)
+			continue
+		}
+		if actual != c.expected {
+			t.Errorf("%s => (expected) %q != %q (actual)", c.spec, c.expected, actual)
+		}
+	}
+
+	_, err := Describe("H H * * * *")
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Errorf("expected a *ParseError without hash key, got %v", err)
+	}
+}
@@ -21,6 +21,9 @@ them in their own goroutines.
 	inspect(c.Entries())
 	..
 	c.Stop()  // Stop the scheduler (does not stop any jobs already running).
+	..
+	// Or stop the scheduler and wait for the jobs already running.
+	c.Shutdown(ctx)
 
 CRON Expression Format
 
@@ -2,11 +2,32 @@ package etcdcron
 
 import (
 	"context"
+	"fmt"
+	"sync"
+	"time"
 
+	"github.com/pkg/errors"
 	etcdclient "go.etcd.io/etcd/client/v3"
 	"go.etcd.io/etcd/client/v3/concurrency"
 )
 
+const (
+	// As each task iteration lock name is unique, we don't really care about unlocking it
+	// So the etcd lease will last 10 minutes, it ensures that even if another server
+	// clock is ill-configured (with a maximum span of 10 minutes), it won't execute the task
+	// twice. As long as the session is alive, the stale locks are deleted by the janitor of
+	// the Cron.
+	defaultSessionTTL = 10 * time.Minute
+
+	// sessionGrantTimeout is the maximum duration to get a lease from etcd when
+	// creating a session.
+	sessionGrantTimeout = 5 * time.Second
+)
+
+// ErrMutexBuilderClosed is returned when creating a mutex with a closed
+// EtcdMutexBuilder.
+var ErrMutexBuilderClosed = errors.New("etcd mutex builder closed")
+
 type DistributedMutex interface {
 	IsOwner() etcdclient.Cmp
 	Key() string
@@ -18,30 +39,218 @@ type EtcdMutexBuilder interface {
 	NewMutex(pfx string) (DistributedMutex, error)
 }
 
+// etcdMutexBuilder creates all its mutexes with a single etcd session. The
+// session is created again if its lease is lost.
 type etcdMutexBuilder struct {
 	*etcdclient.Client
+	// ownsClient is true if the client has been created by the builder, and must
+	// be closed with it.
+	ownsClient bool
+	sessionTTL time.Duration
+
+	mutex   sync.Mutex
+	session *concurrency.Session
+	closed  bool
+}
+
+type EtcdMutexBuilderOpt func(b *etcdMutexBuilder)
+
+// WithSessionTTL sets the TTL of the lease of the etcd session (10 minutes by
+// default, rounded down to the second). The iteration locks are kept at least
+// for this duration, which is the maximum clock drift tolerated between two
+// nodes before running an iteration twice.
+func WithSessionTTL(ttl time.Duration) EtcdMutexBuilderOpt {
+	return EtcdMutexBuilderOpt(func(b *etcdMutexBuilder) {
+		b.sessionTTL = ttl
+	})
 }
 
-func NewEtcdMutexBuilderFromClient(c *etcdclient.Client) (EtcdMutexBuilder, error) {
-	return etcdMutexBuilder{Client: c}, nil
+// NewEtcdMutexBuilderFromClient returns a mutex builder using the given etcd
+// client, which is not closed with the builder. The builder implements
+// io.Closer, it must be closed once unused:
+//
+//	defer builder.(io.Closer).Close()
+func NewEtcdMutexBuilderFromClient(c *etcdclient.Client, opts ...EtcdMutexBuilderOpt) (EtcdMutexBuilder, error) {
+	return newEtcdMutexBuilder(c, false, opts)
 }
 
-func NewEtcdMutexBuilder(config etcdclient.Config) (EtcdMutexBuilder, error) {
+// NewEtcdMutexBuilder returns a mutex builder using a new etcd client created
+// with the given config. The builder implements io.Closer, closing it also
+// closes the client:
+//
+//	defer builder.(io.Closer).Close()
+func NewEtcdMutexBuilder(config etcdclient.Config, opts ...EtcdMutexBuilderOpt) (EtcdMutexBuilder, error) {
 	c, err := etcdclient.New(config)
 	if err != nil {
 		return nil, err
 	}
-	return etcdMutexBuilder{Client: c}, nil
+	b, err := newEtcdMutexBuilder(c, true, opts)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return b, nil
 }
 
-func (c etcdMutexBuilder) NewMutex(pfx string) (DistributedMutex, error) {
-	// As each task iteration lock name is unique, we don't really care about unlocking it
-	// So the etcd lease will last 10 minutes, it ensures that even if another server
-	// clock is ill-configured (with a maximum span of 10 minutes), it won't execute the task
-	// twice.
-	session, err := concurrency.NewSession(c.Client, concurrency.WithTTL(60*10))
+func newEtcdMutexBuilder(c *etcdclient.Client, ownsClient bool, opts []EtcdMutexBuilderOpt) (*etcdMutexBuilder, error) {
+	b := &etcdMutexBuilder{Client: c, ownsClient: ownsClient, sessionTTL: defaultSessionTTL}
+	for _, opt := range opts {
+		opt(b)
+	}
+	if b.sessionTTL < time.Second {
+		return nil, errors.Errorf("invalid session TTL %v, it must be at least 1s", b.sessionTTL)
+	}
+	return b, nil
+}
+
+// EtcdClient returns the etcd client used to create the mutexes.
+func (c *etcdMutexBuilder) EtcdClient() *etcdclient.Client {
+	return c.Client
+}
+
+// SessionTTL returns the TTL of the lease of the etcd session.
+func (c *etcdMutexBuilder) SessionTTL() time.Duration {
+	return c.sessionTTL
+}
+
+func (c *etcdMutexBuilder) NewMutex(pfx string) (DistributedMutex, error) {
+	session, err := c.getSession()
 	if err != nil {
 		return nil, err
 	}
-	return concurrency.NewMutex(session, pfx), nil
+	return &sessionMutex{
+		Mutex:   concurrency.NewMutex(session, pfx),
+		client:  c.Client,
+		lease:   session.Lease(),
+		waitKey: fmt.Sprintf("%s/%x", pfx, session.Lease()),
+	}, nil
+}
+
+// sessionMutex is a concurrency.Mutex which can't be acquired twice with the
+// same session. The key of a concurrency.Mutex is derived from the lease of its
+// session, and a lease already holding the key acquires it again: as the
+// mutexes share the session of the builder, two crons of the process using the
+// builder would run the same iteration.
+type sessionMutex struct {
+	*concurrency.Mutex
+	client *etcdclient.Client
+	lease  etcdclient.LeaseID
+	// waitKey is the key of the mutex in the waiters of the lock
+	waitKey string
+}
+
+// Lock creates the key of the mutex if no other mutex of the session holds or
+// waits for the lock, otherwise it waits for the key to be deleted. The lock
+// is then acquired as a concurrency.Mutex.
+func (m *sessionMutex) Lock(ctx context.Context) error {
+	for {
+		resp, err := m.client.Txn(ctx).
+			If(etcdclient.Compare(etcdclient.CreateRevision(m.waitKey), "=", 0)).
+			Then(etcdclient.OpPut(m.waitKey, "", etcdclient.WithLease(m.lease))).
+			Commit()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		} else if err != nil {
+			return err
+		}
+		if resp.Succeeded {
+			break
+		}
+		err = m.waitRelease(ctx, resp.Header.Revision)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := m.Mutex.Lock(ctx)
+	if err != nil {
+		// The concurrency.Mutex deletes its key when waiting for the lock fails,
+		// but not when it fails before. The key must not prevent the other mutexes
+		// of the session from locking.
+		if m.Mutex.Key() != "\x00" {
+			deleteCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sessionGrantTimeout)
+			defer cancel()
+			_, _ = m.client.Delete(deleteCtx, m.waitKey)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return nil
+}
+
+// waitRelease waits for the key of the mutex, held by another mutex of the
+// session at the given revision, to be deleted.
+func (m *sessionMutex) waitRelease(ctx context.Context, revision int64) error {
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	watch := m.client.Watch(watchCtx, m.waitKey, etcdclient.WithRev(revision+1), etcdclient.WithFilterPut())
+	for resp := range watch {
+		if err := resp.Err(); err != nil {
+			return err
+		}
+		if len(resp.Events) > 0 {
+			return nil
+		}
+	}
+	// The watch is closed, the key is checked again.
+	return ctx.Err()
+}
+
+// getSession returns the shared session, creating it if it does not exist yet
+// or if its lease has been lost (ie. etcd unreachable for longer than the TTL).
+func (c *etcdMutexBuilder) getSession() (*concurrency.Session, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return nil, ErrMutexBuilderClosed
+	}
+	if c.session != nil {
+		select {
+		case <-c.session.Done():
+			// The lease expired or has been revoked, the keys attached to it are gone.
+			c.session = nil
+		default:
+			return c.session, nil
+		}
+	}
+
+	// The lease is granted before creating the session, as the context given to
+	// the session would also stop its keep alive.
+	ctx, cancel := context.WithTimeout(c.Client.Ctx(), sessionGrantTimeout)
+	defer cancel()
+	lease, err := c.Client.Grant(ctx, int64(c.sessionTTL/time.Second))
+	if err != nil {
+		return nil, errors.Wrap(err, "fail to grant etcd lease")
+	}
+	session, err := concurrency.NewSession(c.Client, concurrency.WithLease(lease.ID))
+	if err != nil {
+		return nil, errors.Wrap(err, "fail to create etcd session")
+	}
+	c.session = session
+	return session, nil
+}
+
+// Close implements io.Closer. It stops refreshing the session, whose lease is
+// not revoked: the iteration locks are kept until it expires, so that they
+// can't be acquired again by another node. The etcd client is closed if it has
+// been created by the builder.
+func (c *etcdMutexBuilder) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	if c.session != nil {
+		c.session.Orphan()
+		c.session = nil
+	}
+	if c.ownsClient {
+		return c.Client.Close()
+	}
+	return nil
 }
@@ -0,0 +1,123 @@
+package etcdcron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"testing"
+	"time"
+
+	etcdclient "go.etcd.io/etcd/client/v3"
+)
+
+// Test that all the mutexes are created with the same session.
+func TestEtcdMutexBuilderSharedSession(t *testing.T) {
+	builder, err := NewEtcdMutexBuilder(etcdclient.Config{Endpoints: []string{defaultEtcdEndpoint}})
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	defer builder.(io.Closer).Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), ONE_SECOND)
+	defer cancel()
+
+	var leases []string
+	for _, key := range []string{"etcd_cron/test-shared-session/1", "etcd_cron/test-shared-session/2"} {
+		m, err := builder.NewMutex(key)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		err = m.Lock(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer m.Unlock(context.Background())
+		leases = append(leases, path.Base(m.Key()))
+	}
+
+	if leases[0] != leases[1] {
+		t.Errorf("expected mutexes to share the same lease, got %v", leases)
+	}
+}
+
+// Test that two mutexes of the same builder, sharing the session, can't hold
+// the same lock.
+func TestEtcdMutexBuilderSameKey(t *testing.T) {
+	builder, err := NewEtcdMutexBuilder(etcdclient.Config{Endpoints: []string{defaultEtcdEndpoint}})
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	defer builder.(io.Closer).Close()
+
+	key := fmt.Sprintf("etcd_cron/test-same-key/%d", time.Now().UnixNano())
+	first, err := builder.NewMutex(key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := builder.NewMutex(key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ONE_SECOND)
+	defer cancel()
+	err = first.Lock(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lockCtx, cancelLock := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancelLock()
+	err = second.Lock(lockCtx)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected the lock to be held, got %v", err)
+	}
+
+	locked := make(chan error)
+	go func() { locked <- second.Lock(ctx) }()
+	err = first.Unlock(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = <-locked
+	if err != nil {
+		t.Fatalf("expected the lock to be acquired once released, got %v", err)
+	}
+	second.Unlock(context.Background())
+}
+
+func TestEtcdMutexBuilderClose(t *testing.T) {
+	builder, err := NewEtcdMutexBuilder(etcdclient.Config{
+		Endpoints:   []string{defaultEtcdEndpoint},
+		DialTimeout: time.Second,
+	})
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	err = builder.(io.Closer).Close()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Closing twice is a no-op.
+	err = builder.(io.Closer).Close()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = builder.NewMutex("etcd_cron/test-close/1")
+	if !errors.Is(err, ErrMutexBuilderClosed) {
+		t.Fatalf("expected ErrMutexBuilderClosed, got %v", err)
+	}
+}
+
+func TestEtcdMutexBuilderInvalidSessionTTL(t *testing.T) {
+	_, err := NewEtcdMutexBuilder(
+		etcdclient.Config{Endpoints: []string{defaultEtcdEndpoint}},
+		WithSessionTTL(500*time.Millisecond),
+	)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,164 @@
+package etcdcron
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// String returns the normalized spec of the schedule, in the format of Parse:
+// the values are given as numbers, the consecutive values and the values
+// separated by the same step being collapsed into ranges, ie.
+// "0 0-30/15 9-17 * * 1-5". Parsing it gives the same schedule.
+func (s *SpecSchedule) String() string {
+	fields := []string{
+		formatField(s.Second, seconds, nil),
+		formatField(s.Minute, minutes, nil),
+		formatField(s.Hour, hours, nil),
+		formatField(s.Dom, dom, s.domModifiers()),
+		formatField(s.Month, months, nil),
+		formatField(s.Dow, dow, s.dowModifiers()),
+	}
+	if s.Year != nil {
+		fields = append(fields, formatValues(yearSet(s.Year).values, years))
+	}
+	spec := strings.Join(fields, " ")
+	if s.Location != nil {
+		spec = fmt.Sprintf("CRON_TZ=%v %v", s.Location, spec)
+	}
+	return spec
+}
+
+// MarshalText returns the normalized spec of the schedule.
+func (s *SpecSchedule) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText parses the spec into the schedule. The spec must not be an
+// @every descriptor.
+func (s *SpecSchedule) UnmarshalText(text []byte) error {
+	schedule, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	spec, ok := schedule.(*SpecSchedule)
+	if !ok {
+		return errors.Errorf("spec %q is not a cron spec", text)
+	}
+	*s = *spec
+	return nil
+}
+
+// String returns the @every descriptor of the schedule.
+func (schedule ConstantDelaySchedule) String() string {
+	return "@every " + schedule.Delay.String()
+}
+
+// MarshalText returns the @every descriptor of the schedule.
+func (schedule ConstantDelaySchedule) MarshalText() ([]byte, error) {
+	return []byte(schedule.String()), nil
+}
+
+// UnmarshalText parses the @every descriptor into the schedule.
+func (schedule *ConstantDelaySchedule) UnmarshalText(text []byte) error {
+	parsed, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	constantDelay, ok := parsed.(ConstantDelaySchedule)
+	if !ok {
+		return errors.Errorf("spec %q is not an @every descriptor", text)
+	}
+	*schedule = constantDelay
+	return nil
+}
+
+func (s *SpecSchedule) domModifiers() []string {
+	var modifiers []string
+	if s.LastDay {
+		modifiers = append(modifiers, "L")
+	}
+	if s.LastWeekday {
+		modifiers = append(modifiers, "LW")
+	}
+	for _, day := range newFieldSet(s.NearestWeekday, dom).values {
+		modifiers = append(modifiers, fmt.Sprintf("%dW", day))
+	}
+	return modifiers
+}
+
+func (s *SpecSchedule) dowModifiers() []string {
+	var modifiers []string
+	for _, day := range newFieldSet(s.LastDow, dow).values {
+		modifiers = append(modifiers, fmt.Sprintf("%dL", day))
+	}
+	for i := uint(0); i < 7*maxNthDow; i++ {
+		if 1<<i&s.NthDow > 0 {
+			modifiers = append(modifiers, fmt.Sprintf("%d#%d", i%7, i/7+1))
+		}
+	}
+	return modifiers
+}
+
+// formatField returns the list of the values of the field and its modifiers.
+// If the field has the star bit, the list starts with a star, with the step
+// covering the most values.
+func formatField(bits uint64, r bounds, modifiers []string) string {
+	var items []string
+	if bits&starBit > 0 {
+		for step := uint(1); step <= r.max-r.min+1; step++ {
+			stepBits := getBits(r.min, r.max, step)
+			if bits&stepBits != stepBits {
+				continue
+			}
+			if step == 1 {
+				items = append(items, "*")
+			} else {
+				items = append(items, fmt.Sprintf("*/%d", step))
+			}
+			bits &^= stepBits
+			break
+		}
+	}
+	if values := newFieldSet(bits, r).values; len(values) > 0 {
+		items = append(items, formatValues(values, r))
+	}
+	items = append(items, modifiers...)
+	return strings.Join(items, ",")
+}
+
+// formatValues returns the list of the values, collapsing the consecutive
+// values into ranges ("1-5"), and the values separated by the same step into
+// ranges with step ("0-30/15", or "5/15" up to the end of the field).
+func formatValues(values []uint, r bounds) string {
+	items := []string{}
+	for i := 0; i < len(values); {
+		j := i + 1
+		var step uint
+		if j < len(values) {
+			step = values[j] - values[i]
+			for j+1 < len(values) && values[j+1]-values[j] == step {
+				j++
+			}
+		}
+		if j-i < 2 {
+			// Less than 3 values separated by the same step
+			items = append(items, fmt.Sprint(values[i]))
+			i++
+			continue
+		}
+
+		start, end := values[i], values[j]
+		switch {
+		case step == 1:
+			items = append(items, fmt.Sprintf("%d-%d", start, end))
+		case end+step > r.max:
+			items = append(items, fmt.Sprintf("%d/%d", start, step))
+		default:
+			items = append(items, fmt.Sprintf("%d-%d/%d", start, end, step))
+		}
+		i = j + 1
+	}
+	return strings.Join(items, ",")
+}
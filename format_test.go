@@ -0,0 +1,177 @@
+package etcdcron
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSpecScheduleString(t *testing.T) {
+	entries := []struct {
+		spec, expected string
+	}{
+		{"* * * * * *", "* * * * * *"},
+		{"0 */15 9-17 ? * mon-fri", "0 */15 9-17 * * 1-5"},
+		{"0 0,15,30 * * * *", "0 0-30/15 * * * *"},
+		{"0 5/15 * * * *", "0 5/15 * * * *"},
+		{"0 0 0 * * * 2080/5", "0 0 0 * * * 2080/5"},
+		{"0 1,2,3,5,7,9,20 * * * *", "0 1-3,5-9/2,20 * * * *"},
+		{"0 1,2 * * * *", "0 1,2 * * * *"},
+		{"0-59 * * * * *", "0-59 * * * * *"},
+		{"*/10,5 * * * * *", "*/10,5 * * * * *"},
+		{"0 0 0 1,L,LW,15W * ?", "0 0 0 1,L,LW,15W * *"},
+		{"0 0 0 ? * FriL,2#2,Sun#5", "0 0 0 * * 5L,2#2,0#5"},
+		{"0 0 0 1 Jan ? 2027,2030-2040/5", "0 0 0 1 1 * 2027,2030-2040/5"},
+		{"@hourly", "0 0 * * * *"},
+		{"CRON_TZ=Europe/Paris 0 0 9 * * *", "CRON_TZ=Europe/Paris 0 0 9 * * *"},
+		{"@every 1h30m", "@every 1h30m0s"},
+	}
+
+	for _, c := range entries {
+		schedule, err := Parse(c.spec)
+		if err != nil {
+			t.Fatalf("%s => unexpected error %v", c.spec, err)
+		}
+		actual := fmt.Sprint(schedule)
+		if actual != c.expected {
+			t.Errorf("%s => (expected) %q != %q (actual)", c.spec, c.expected, actual)
+		}
+	}
+}
+
+// Test that parsing the string of a schedule gives the same schedule, for
+// random specs.
+func TestScheduleStringRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	for i := 0; i < 5000; i++ {
+		spec := randomSpec(r)
+		schedule, err := Parse(spec)
+		if err != nil {
+			t.Fatalf("%s => unexpected error %v", spec, err)
+		}
+		formatted := fmt.Sprint(schedule)
+		actual, err := Parse(formatted)
+		if err != nil {
+			t.Fatalf("%s => %s => unexpected error %v", spec, formatted, err)
+		}
+		if !reflect.DeepEqual(actual, schedule) {
+			t.Fatalf("%s => %s => (expected) %+v != %+v (actual)", spec, formatted, schedule, actual)
+		}
+		if again := fmt.Sprint(actual); again != formatted {
+			t.Fatalf("%s => (expected) %q != %q (actual)", spec, formatted, again)
+		}
+	}
+}
+
+// randomSpec returns a random valid spec, with descriptors, time zones,
+// modifiers and years.
+func randomSpec(r *rand.Rand) string {
+	switch r.IntN(20) {
+	case 0:
+		return []string{"@yearly", "@monthly", "@weekly", "@daily", "@hourly"}[r.IntN(5)]
+	case 1:
+		return fmt.Sprintf("@every %v", time.Duration(1+r.IntN(100000))*time.Second)
+	}
+
+	fields := []string{
+		randomField(r, seconds, nil),
+		randomField(r, minutes, nil),
+		randomField(r, hours, nil),
+		randomField(r, dom, func() string {
+			return []string{"L", "LW", fmt.Sprintf("%dW", 1+r.IntN(31))}[r.IntN(3)]
+		}),
+		randomField(r, months, nil),
+		randomField(r, dow, func() string {
+			if r.IntN(2) == 0 {
+				return fmt.Sprintf("%dL", r.IntN(7))
+			}
+			return fmt.Sprintf("%d#%d", r.IntN(7), 1+r.IntN(5))
+		}),
+	}
+	if r.IntN(3) == 0 {
+		fields = append(fields, randomField(r, years, nil))
+	}
+	spec := strings.Join(fields, " ")
+	if r.IntN(4) == 0 {
+		spec = []string{"CRON_TZ=UTC ", "TZ=Europe/Paris ", "CRON_TZ=America/New_York "}[r.IntN(3)] + spec
+	}
+	return spec
+}
+
+// randomField returns a random list of ranges within the bounds, and of
+// modifiers if given.
+func randomField(r *rand.Rand, b bounds, modifier func() string) string {
+	size := b.max - b.min + 1
+	value := func() uint { return b.min + uint(r.IntN(int(size))) }
+	items := make([]string, 1+r.IntN(3))
+	for i := range items {
+		switch r.IntN(7) {
+		case 0:
+			items[i] = "*"
+		case 1:
+			items[i] = fmt.Sprintf("*/%d", 1+r.IntN(int(size)))
+		case 2:
+			start := value()
+			items[i] = fmt.Sprintf("%d/%d", start, 1+r.IntN(int(size)))
+		case 3:
+			start := value()
+			end := start + uint(r.IntN(int(b.max-start+1)))
+			items[i] = fmt.Sprintf("%d-%d", start, end)
+		case 4:
+			start := value()
+			end := start + uint(r.IntN(int(b.max-start+1)))
+			items[i] = fmt.Sprintf("%d-%d/%d", start, end, 1+r.IntN(int(size)))
+		case 5:
+			if modifier != nil {
+				items[i] = modifier()
+				continue
+			}
+			fallthrough
+		default:
+			items[i] = fmt.Sprint(value())
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+func TestScheduleJSON(t *testing.T) {
+	type schedules struct {
+		Spec          *SpecSchedule         `json:"spec"`
+		ConstantDelay ConstantDelaySchedule `json:"constant_delay"`
+	}
+	spec, _ := Parse("CRON_TZ=UTC 0 */15 9-17 L * ? 2027")
+	expected := schedules{Spec: spec.(*SpecSchedule), ConstantDelay: Every(90 * time.Second)}
+
+	data, err := json.Marshal(expected)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if string(data) != `{"spec":"CRON_TZ=UTC 0 */15 9-17 L * * 2027","constant_delay":"@every 1m30s"}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+	var actual schedules
+	err = json.Unmarshal(data, &actual)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("(expected) %+v != %+v (actual)", expected, actual)
+	}
+
+	invalid := []string{
+		`{"spec":"@every 5m"}`,
+		`{"spec":"0 60 * * *"}`,
+		`{"constant_delay":"@daily"}`,
+		`{"constant_delay":1}`,
+	}
+	for _, data := range invalid {
+		var actual schedules
+		if err := json.Unmarshal([]byte(data), &actual); err == nil {
+			t.Errorf("%s => expected an error", data)
+		}
+	}
+}
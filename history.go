@@ -0,0 +1,170 @@
+package etcdcron
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	etcdclient "go.etcd.io/etcd/client/v3"
+)
+
+const defaultHistoryLimit = 100
+
+// ErrNoEtcdClient is returned by the features storing data in etcd when the
+// EtcdMutexBuilder does not give access to its etcd client.
+var ErrNoEtcdClient = errors.New("the etcd mutex builder does not give access to an etcd client")
+
+// ExecutionOutcome is the result of an execution.
+type ExecutionOutcome string
+
+const (
+	ExecutionSuccess ExecutionOutcome = "success"
+	ExecutionFailure ExecutionOutcome = "failure"
+	ExecutionTimeout ExecutionOutcome = "timeout"
+	ExecutionPanic   ExecutionOutcome = "panic"
+)
+
+// Execution is the record of an iteration of a job, stored in etcd by the node
+// which ran it.
+type Execution struct {
+	// Name of the job
+	Job string `json:"job"`
+	// Activation time of the iteration
+	ScheduledAt time.Time `json:"scheduled_at"`
+	// Identifier of the node which ran the iteration, see WithNodeID
+	NodeID     string           `json:"node_id"`
+	StartedAt  time.Time        `json:"started_at"`
+	FinishedAt time.Time        `json:"finished_at"`
+	Outcome    ExecutionOutcome `json:"outcome"`
+	// Error returned by the last attempt, if any
+	Error string `json:"error,omitempty"`
+}
+
+// WithNodeID sets the identifier of the node stored in the execution records
+// (<hostname>-<pid> by default).
+func WithNodeID(id string) CronOpt {
+	return CronOpt(func(cron *Cron) {
+		cron.nodeID = id
+	})
+}
+
+// WithHistoryLimit sets the number of execution records kept in etcd for each
+// job (100 by default), 0 disables the history.
+func WithHistoryLimit(limit int) CronOpt {
+	return CronOpt(func(cron *Cron) {
+		cron.historyLimit = limit
+	})
+}
+
+func defaultNodeID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+func (c *Cron) historyPrefix(job Job) string {
+	return c.jobKey(job, "history") + "/"
+}
+
+// History returns the last executions of the job with the given name, whichever
+// node ran them, the most recent first. At most limit executions are returned,
+// all the stored executions if limit is 0.
+func (c *Cron) History(ctx context.Context, jobName string, limit int) ([]Execution, error) {
+	client, ok := c.etcdClient()
+	if !ok {
+		return nil, ErrNoEtcdClient
+	}
+
+	resp, err := client.Get(ctx, c.historyPrefix(Job{Name: jobName}),
+		etcdclient.WithPrefix(),
+		etcdclient.WithSort(etcdclient.SortByCreateRevision, etcdclient.SortDescend),
+		etcdclient.WithLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, errors.Wrapf(err, "fail to get history of job '%v'", jobName)
+	}
+
+	executions := make([]Execution, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		var execution Execution
+		err := json.Unmarshal(kv.Value, &execution)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid execution record '%s'", kv.Key)
+		}
+		executions = append(executions, execution)
+	}
+	return executions, nil
+}
+
+// recordExecution stores the execution record in etcd and deletes the oldest
+// records of the job beyond the history limit. lockName is the name of the
+// iteration lock, unique for each iteration of the job.
+func (c *Cron) recordExecution(ctx context.Context, job Job, lockName string, execution Execution) {
+	client, ok := c.etcdClient()
+	if !ok || c.historyLimit == 0 {
+		return
+	}
+	ctx, cancel := c.detachedContext(ctx)
+	defer cancel()
+
+	err := c.storeExecution(ctx, client, job, lockName, execution)
+	if err != nil {
+		go c.etcdErrorsHandler(ctx, job, errors.Wrapf(err, "fail to record execution of job '%v'", job.Name))
+	}
+}
+
+// storeExecution stores the record under a key unique to the iteration and the
+// node: the manual iterations may be scheduled at the same second as another
+// iteration, a record must not overwrite another one.
+func (c *Cron) storeExecution(ctx context.Context, client *etcdclient.Client, job Job, lockName string, execution Execution) error {
+	value, err := json.Marshal(execution)
+	if err != nil {
+		return errors.Wrap(err, "fail to encode execution record")
+	}
+	prefix := c.historyPrefix(job)
+	_, err = client.Put(ctx, prefix+lockName+"_"+execution.NodeID, string(value))
+	if err != nil {
+		return errors.Wrap(err, "fail to store execution record")
+	}
+
+	resp, err := client.Get(ctx, prefix, etcdclient.WithPrefix(), etcdclient.WithCountOnly())
+	if err != nil {
+		return errors.Wrap(err, "fail to count execution records")
+	}
+	excess := resp.Count - int64(c.historyLimit)
+	if excess <= 0 {
+		return nil
+	}
+	resp, err = client.Get(ctx, prefix,
+		etcdclient.WithPrefix(), etcdclient.WithKeysOnly(),
+		etcdclient.WithSort(etcdclient.SortByCreateRevision, etcdclient.SortAscend),
+		etcdclient.WithLimit(excess),
+	)
+	if err != nil {
+		return errors.Wrap(err, "fail to list oldest execution records")
+	}
+	for _, kv := range resp.Kvs {
+		_, err := client.Delete(ctx, string(kv.Key))
+		if err != nil {
+			return errors.Wrapf(err, "fail to delete execution record '%s'", kv.Key)
+		}
+	}
+	return nil
+}
+
+// executionOutcome returns the outcome of an execution which returned err.
+func executionOutcome(err error) ExecutionOutcome {
+	var timeoutErr *TimeoutError
+	switch {
+	case err == nil:
+		return ExecutionSuccess
+	case errors.As(err, &timeoutErr):
+		return ExecutionTimeout
+	}
+	return ExecutionFailure
+}
@@ -0,0 +1,138 @@
+package etcdcron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	etcdclient "go.etcd.io/etcd/client/v3"
+)
+
+func TestExecutionOutcome(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected ExecutionOutcome
+	}{
+		{nil, ExecutionSuccess},
+		{errors.New("failure"), ExecutionFailure},
+		{&TimeoutError{Timeout: time.Second}, ExecutionTimeout},
+		{&AttemptError{Attempt: 3, MaxAttempts: 3, Err: &TimeoutError{Timeout: time.Second}}, ExecutionTimeout},
+	}
+
+	for _, test := range tests {
+		actual := executionOutcome(test.err)
+		if actual != test.expected {
+			t.Errorf("%v => (expected) %v != %v (actual)", test.err, test.expected, actual)
+		}
+	}
+}
+
+func TestHistoryWithoutEtcdClient(t *testing.T) {
+	cron, err := New(WithEtcdMutexBuilder(newMemoryMutexBuilder()))
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	_, err = cron.History(context.Background(), "job", 10)
+	if !errors.Is(err, ErrNoEtcdClient) {
+		t.Fatalf("expected ErrNoEtcdClient, got %v", err)
+	}
+}
+
+// Test that the executions are recorded in etcd, and that the oldest records
+// are deleted.
+func TestHistory(t *testing.T) {
+	prefix := fmt.Sprintf("etcd_cron_test_history_%d", time.Now().UnixNano())
+	runs := make(chan struct{}, 10)
+	cron, err := New(
+		WithKeyPrefix(prefix),
+		WithNodeID("node-1"),
+		WithHistoryLimit(2),
+	)
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	defer cron.Close()
+	client, _ := cron.etcdClient()
+	defer client.Delete(context.Background(), prefix+"/", etcdclient.WithPrefix())
+
+	cron.AddJob(Job{
+		Name:   "test-history",
+		Rhythm: "* * * * * ?",
+		Func: func(context.Context) error {
+			runs <- struct{}{}
+			return errors.New("failure")
+		},
+	})
+	cron.Start(context.Background())
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-time.After(2 * ONE_SECOND):
+			t.Fatal("expected job to run")
+		case <-runs:
+		}
+	}
+	err = cron.Shutdown(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ONE_SECOND)
+	defer cancel()
+	executions, err := cron.History(ctx, "test-history", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(executions) != 2 {
+		t.Fatalf("expected 2 executions, got %d", len(executions))
+	}
+	if !executions[0].ScheduledAt.After(executions[1].ScheduledAt) {
+		t.Errorf("expected most recent execution first")
+	}
+	for _, execution := range executions {
+		if execution.NodeID != "node-1" || execution.Outcome != ExecutionFailure || execution.Error != "failure" {
+			t.Errorf("unexpected execution %+v", execution)
+		}
+	}
+}
+
+// Test that the iterations scheduled at the same second, ie. manual ones, get
+// a record each.
+func TestHistorySameSecond(t *testing.T) {
+	prefix := fmt.Sprintf("etcd_cron_test_history_same_second_%d", time.Now().UnixNano())
+	cron, err := New(
+		WithKeyPrefix(prefix),
+		WithClock(NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))),
+	)
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	defer cron.Close()
+	client, _ := cron.etcdClient()
+	defer client.Delete(context.Background(), prefix+"/", etcdclient.WithPrefix())
+
+	cron.AddJob(Job{
+		Name:   "test-history-same-second",
+		Rhythm: "@yearly",
+		Func:   func(context.Context) error { return nil },
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*ONE_SECOND)
+	defer cancel()
+	for i := 0; i < 2; i++ {
+		err = cron.Trigger(ctx, "test-history-same-second")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	executions, err := cron.History(ctx, "test-history-same-second", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(executions) != 2 {
+		t.Fatalf("expected 2 executions, got %d", len(executions))
+	}
+}
@@ -0,0 +1,202 @@
+package etcdcron
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	etcdclient "go.etcd.io/etcd/client/v3"
+)
+
+const (
+	defaultJanitorInterval = time.Minute
+	// janitorPageSize is the number of keys listed at once by the janitor.
+	janitorPageSize = 1000
+)
+
+// janitorJob is given to the etcd errors handler when the janitor fails.
+var janitorJob = Job{Name: "janitor"}
+
+// JanitorStats are the metrics of the janitor deleting the stale iteration
+// locks from etcd.
+type JanitorStats struct {
+	// Number of cleanups since the cron has been created
+	Runs int64
+	// Number of keys deleted since the cron has been created
+	DeletedKeys int64
+	// Number of keys deleted by the last cleanup
+	LastDeletedKeys int64
+	// Time of the last cleanup
+	LastRun time.Time
+}
+
+type janitor struct {
+	mutex sync.Mutex
+	stats JanitorStats
+	// revisions are the etcd revisions seen by the last cleanups, the oldest
+	// first, to know when the keys have been created.
+	revisions []revisionSample
+}
+
+// revisionSample is the etcd revision at a given time.
+type revisionSample struct {
+	at       time.Time
+	revision int64
+}
+
+// staleRevision records the revision seen at now, and returns the last revision
+// seen before staleBefore: the keys created at this revision or before are
+// older than staleBefore. It returns 0 if no revision has been seen that long
+// ago.
+func (j *janitor) staleRevision(now time.Time, revision int64, staleBefore time.Time) int64 {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.revisions = append(j.revisions, revisionSample{at: now, revision: revision})
+
+	var stale int64
+	i := 0
+	for ; i < len(j.revisions) && !j.revisions[i].at.After(staleBefore); i++ {
+		stale = j.revisions[i].revision
+	}
+	if i > 1 {
+		// Only the last sample before staleBefore is needed anymore.
+		j.revisions = j.revisions[i-1:]
+	}
+	return stale
+}
+
+func (j *janitor) record(now time.Time, deleted int64) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.stats.Runs++
+	j.stats.DeletedKeys += deleted
+	j.stats.LastDeletedKeys = deleted
+	j.stats.LastRun = now
+}
+
+// WithJanitorInterval sets the interval between two cleanups of the stale
+// iteration locks (1 minute by default), 0 disables the cleanups. The errors
+// of the janitor are given to the etcd errors handler with a Job named
+// "janitor".
+func WithJanitorInterval(interval time.Duration) CronOpt {
+	return CronOpt(func(cron *Cron) {
+		cron.janitorInterval = interval
+	})
+}
+
+// JanitorStats returns the metrics of the janitor deleting the stale iteration
+// locks from etcd.
+func (c *Cron) JanitorStats() JanitorStats {
+	c.janitor.mutex.Lock()
+	defer c.janitor.mutex.Unlock()
+	return c.janitor.stats
+}
+
+// sessionTTLProvider is implemented by the EtcdMutexBuilder giving the TTL of
+// the lease of their mutexes.
+type sessionTTLProvider interface {
+	SessionTTL() time.Duration
+}
+
+// lockRetention returns the duration during which an iteration lock must be
+// kept after its activation time. The iteration locks would have been deleted
+// after the session TTL if the lease had not been shared. As both the node
+// deleting the lock and the node trying to acquire it may have a clock drift
+// up to the session TTL, the locks are kept twice as long.
+func (c *Cron) lockRetention() time.Duration {
+	ttl := defaultSessionTTL
+	if p, ok := c.etcdclient.(sessionTTLProvider); ok {
+		ttl = p.SessionTTL()
+	}
+	return 2 * ttl
+}
+
+// runJanitor deletes periodically the stale iteration locks until ctx is
+// done.
+func (c *Cron) runJanitor(ctx context.Context) {
+	client, ok := c.etcdClient()
+	if !ok || c.janitorInterval == 0 {
+		return
+	}
+
+	for {
+		timer := c.clock.NewTimer(c.janitorInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+
+		now := c.clock.Now()
+		deleted, err := c.cleanLockKeys(ctx, client, now)
+		if err != nil && ctx.Err() == nil {
+			go c.etcdErrorsHandler(ctx, janitorJob, errors.Wrap(err, "fail to clean stale iteration locks"))
+		}
+		c.janitor.record(now, deleted)
+	}
+}
+
+// cleanLockKeys deletes the iteration locks (<prefix>/<job>/<unix>/<lease>)
+// whose activation time and creation are older than the lock retention. It
+// returns the number of deleted keys.
+//
+// The creation of the keys is known from their revision: the locks of the
+// missed iterations run late have an old activation time, they must be kept
+// as long as the other ones. The keys are therefore only deleted once this
+// process has been cleaning them up for the lock retention.
+func (c *Cron) cleanLockKeys(ctx context.Context, client *etcdclient.Client, now time.Time) (int64, error) {
+	prefix := c.keyPrefix + "/"
+	end := etcdclient.GetPrefixRangeEnd(prefix)
+	staleBefore := now.Add(-c.lockRetention())
+
+	var deleted int64
+	var staleRevision int64
+	key := prefix
+	for {
+		resp, err := client.Get(ctx, key,
+			etcdclient.WithRange(end), etcdclient.WithKeysOnly(),
+			etcdclient.WithLimit(janitorPageSize),
+			etcdclient.WithSort(etcdclient.SortByKey, etcdclient.SortAscend),
+		)
+		if err != nil {
+			return deleted, errors.Wrap(err, "fail to list keys")
+		}
+		if key == prefix {
+			staleRevision = c.janitor.staleRevision(now, resp.Header.Revision, staleBefore)
+		}
+		for _, kv := range resp.Kvs {
+			activation, ok := parseLockKey(strings.TrimPrefix(string(kv.Key), prefix))
+			if !ok || activation >= staleBefore.Unix() || kv.CreateRevision > staleRevision {
+				continue
+			}
+			_, err := client.Delete(ctx, string(kv.Key))
+			if err != nil {
+				return deleted, errors.Wrapf(err, "fail to delete key '%s'", kv.Key)
+			}
+			deleted++
+		}
+		if !resp.More || len(resp.Kvs) == 0 {
+			return deleted, nil
+		}
+		key = string(resp.Kvs[len(resp.Kvs)-1].Key) + "\x00"
+	}
+}
+
+// parseLockKey returns the activation time of an iteration lock key, relative
+// to the prefix: <job>/<unix>/<lease>. It returns false if the key is not an
+// iteration lock.
+func parseLockKey(key string) (int64, bool) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 3 {
+		return 0, false
+	}
+	activation, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return activation, true
+}
@@ -0,0 +1,159 @@
+package etcdcron
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	etcdclient "go.etcd.io/etcd/client/v3"
+)
+
+func TestParseLockKey(t *testing.T) {
+	tests := []struct {
+		key        string
+		activation int64
+		ok         bool
+	}{
+		{"job/1341845100/694d8b5a3c1d2e0f", 1341845100, true},
+		{"job_2/1341845100/694d8b5a3c1d2e0f", 1341845100, true},
+		{"job/running/694d8b5a3c1d2e0f", 0, false},
+		{"job/cancel", 0, false},
+		{"job/1341845100", 0, false},
+		{"other/app/job/1341845100/694d8b5a3c1d2e0f", 0, false},
+	}
+
+	for _, test := range tests {
+		activation, ok := parseLockKey(test.key)
+		if ok != test.ok || activation != test.activation {
+			t.Errorf("%s => (expected) %d, %v != %d, %v (actual)", test.key, test.activation, test.ok, activation, ok)
+		}
+	}
+}
+
+func TestLockRetention(t *testing.T) {
+	cron, err := New(WithEtcdMutexBuilder(newMemoryMutexBuilder()))
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	if cron.lockRetention() != 2*defaultSessionTTL {
+		t.Errorf("unexpected lock retention %v", cron.lockRetention())
+	}
+
+	builder, err := NewEtcdMutexBuilder(etcdclient.Config{Endpoints: []string{defaultEtcdEndpoint}}, WithSessionTTL(time.Minute))
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	defer builder.(io.Closer).Close()
+	cron, err = New(WithEtcdMutexBuilder(builder))
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	if cron.lockRetention() != 2*time.Minute {
+		t.Errorf("unexpected lock retention %v", cron.lockRetention())
+	}
+}
+
+func TestJanitorStaleRevision(t *testing.T) {
+	var j janitor
+	start := time.Date(2012, time.July, 9, 14, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time {
+		return start.Add(time.Duration(minutes) * time.Minute)
+	}
+
+	tests := []struct {
+		now         time.Time
+		revision    int64
+		staleBefore time.Time
+		expected    int64
+	}{
+		{at(0), 10, at(-20), 0},
+		{at(1), 20, at(-19), 0},
+		{at(20), 30, at(0), 10},
+		{at(21), 40, at(1), 20},
+		{at(30), 50, at(10), 20},
+		{at(41), 60, at(21), 40},
+	}
+	for _, test := range tests {
+		actual := j.staleRevision(test.now, test.revision, test.staleBefore)
+		if actual != test.expected {
+			t.Errorf("%v => (expected) %d != %d (actual)", test.now, test.expected, actual)
+		}
+	}
+	if len(j.revisions) != 3 {
+		t.Errorf("expected the old revisions to be dropped, got %v", j.revisions)
+	}
+}
+
+// Test that only the stale iteration locks are deleted.
+func TestCleanLockKeys(t *testing.T) {
+	client, err := etcdclient.New(etcdclient.Config{Endpoints: []string{defaultEtcdEndpoint}})
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	defer client.Close()
+
+	prefix := fmt.Sprintf("etcd_cron_test_janitor_%d", time.Now().UnixNano())
+	cron, err := New(WithKeyPrefix(prefix))
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	defer cron.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	defer client.Delete(context.Background(), prefix+"/", etcdclient.WithPrefix())
+
+	now := time.Now()
+	stale := now.Add(-cron.lockRetention() - time.Second).Unix()
+	recent := now.Add(-cron.lockRetention() + time.Minute).Unix()
+	keys := map[string]bool{
+		fmt.Sprintf("%s/job/%d/1", prefix, stale):  true,
+		fmt.Sprintf("%s/job/%d/2", prefix, stale):  true,
+		fmt.Sprintf("%s/job/%d/1", prefix, recent): false,
+		fmt.Sprintf("%s/job/running/1", prefix):    false,
+		fmt.Sprintf("%s/job/cancel", prefix):       false,
+	}
+	for key := range keys {
+		_, err := client.Put(ctx, key, "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// Nothing is deleted until the janitor knows which keys are old enough.
+	deleted, err := cron.cleanLockKeys(ctx, client, now.Add(-cron.lockRetention()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if deleted != 0 {
+		t.Errorf("expected no deleted key, got %d", deleted)
+	}
+
+	// The lock of a missed iteration, with an old activation time, created
+	// recently
+	caughtUp := fmt.Sprintf("%s/job/%d/3", prefix, stale)
+	keys[caughtUp] = false
+	_, err = client.Put(ctx, caughtUp, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	deleted, err = cron.cleanLockKeys(ctx, client, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("expected 2 deleted keys, got %d", deleted)
+	}
+	for key, shouldBeDeleted := range keys {
+		resp, err := client.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if shouldBeDeleted == (resp.Count != 0) {
+			t.Errorf("key %s: expected deleted=%v", key, shouldBeDeleted)
+		}
+	}
+}
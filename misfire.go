@@ -0,0 +1,193 @@
+package etcdcron
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	etcdclient "go.etcd.io/etcd/client/v3"
+)
+
+// defaultMisfireLimit is the maximum number of missed iterations run by the
+// MisfireRunAll policy when the job does not define a MisfireLimit.
+const defaultMisfireLimit = 10
+
+// MisfirePolicy defines what happens to the iterations of a job missed by the
+// whole cluster, ie. because all the nodes were down or etcd was unreachable at
+// their activation time. The missed iterations are the activations after the
+// last iteration of the job handled by a node, stored in etcd: run, whatever
+// its outcome, or skipped on purpose (paused job, overlap policy, shutdown).
+// They are detected when the cron starts and when etcd is reachable again.
+type MisfirePolicy int
+
+const (
+	// MisfireIgnore drops the missed iterations (default).
+	MisfireIgnore MisfirePolicy = iota
+	// MisfireRunOnce runs the most recent missed iteration.
+	MisfireRunOnce
+	// MisfireRunAll runs the missed iterations, the oldest first, up to the
+	// MisfireLimit of the job. The most recent ones are run if more iterations
+	// have been missed.
+	MisfireRunAll
+)
+
+func (p MisfirePolicy) String() string {
+	switch p {
+	case MisfireIgnore:
+		return "ignore"
+	case MisfireRunOnce:
+		return "run-once"
+	case MisfireRunAll:
+		return "run-all"
+	}
+	return "unknown"
+}
+
+// limit returns the maximum number of missed iterations to run.
+func (p MisfirePolicy) limit(job Job) int {
+	switch p {
+	case MisfireRunOnce:
+		return 1
+	case MisfireRunAll:
+		if job.MisfireLimit > 0 {
+			return job.MisfireLimit
+		}
+		return defaultMisfireLimit
+	}
+	return 0
+}
+
+// misfires tracks the jobs whose iterations failed because of etcd, by
+// canonical name. Their missed iterations are run once etcd is reachable again.
+type misfires struct {
+	mutex  sync.Mutex
+	failed map[string]bool
+}
+
+func (m *misfires) setFailed(job Job, failed bool) (wasFailed bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.failed == nil {
+		m.failed = map[string]bool{}
+	}
+	wasFailed = m.failed[job.canonicalName()]
+	if failed {
+		m.failed[job.canonicalName()] = true
+	} else {
+		delete(m.failed, job.canonicalName())
+	}
+	return wasFailed
+}
+
+func (c *Cron) lastHandledKey(job Job) string {
+	return c.jobKey(job, "last_handled")
+}
+
+// startCatchUp runs in the background the iterations of the entry missed since
+// its last handled iteration, according to its misfire policy. It's called by
+// the scheduler goroutine once the next activation of the entry is computed.
+//
+// The missed iterations are the ones due before now, the next activation of
+// the entry being run by the scheduler: the next activation of an @every
+// schedule is computed from now, it must not be caught up.
+func (c *Cron) startCatchUp(ctx context.Context, entry *Entry) {
+	if entry.Job.Misfire == MisfireIgnore || entry.Next.IsZero() {
+		return
+	}
+	until := c.clock.Now().In(entry.Next.Location())
+	if entry.Next.Before(until) {
+		until = entry.Next
+	}
+	c.executions.Add(1)
+	go c.catchUp(ctx, entry.Job, entry.Schedule, until, entry.Next)
+}
+
+// catchUp runs the iterations missed between the last handled iteration of the
+// job and until (excluded). Each iteration takes the lock of the activation it
+// replaces, so that it is run by a single node, and only if no node ran it.
+// next is the following activation time of the job.
+func (c *Cron) catchUp(ctx context.Context, job Job, schedule Schedule, until, next time.Time) {
+	defer c.executions.Done()
+	client, ok := c.etcdClient()
+	if !ok {
+		return
+	}
+
+	getCtx, cancel := context.WithTimeout(ctx, c.lockTimeout)
+	defer cancel()
+	resp, err := client.Get(getCtx, c.lastHandledKey(job))
+	if err != nil {
+		go c.etcdErrorsHandler(ctx, job, errors.Wrapf(err, "fail to get last handled iteration of job '%v'", job.Name))
+		return
+	}
+	if len(resp.Kvs) == 0 {
+		// No iteration of the job has been handled, nothing has been missed.
+		return
+	}
+	last, err := strconv.ParseInt(string(resp.Kvs[0].Value), 10, 64)
+	if err != nil {
+		go c.etcdErrorsHandler(ctx, job, errors.Wrapf(err, "invalid last handled iteration of job '%v'", job.Name))
+		return
+	}
+
+	missed := missedActivations(schedule, time.Unix(last, 0).In(until.Location()), until, job.Misfire.limit(job))
+	for _, activation := range missed {
+		if ctx.Err() != nil || c.isShuttingDown() {
+			return
+		}
+		// The errors are given to the handlers.
+		_ = c.runIteration(ctx, job, iteration{
+			lockName:    strconv.FormatInt(activation.Unix(), 10),
+			scheduledAt: activation,
+			next:        next,
+		})
+	}
+}
+
+// missedActivations returns the last activations of the schedule after last and
+// before until, at most limit of them, the oldest first.
+func missedActivations(schedule Schedule, last, until time.Time, limit int) []time.Time {
+	if limit <= 0 {
+		return nil
+	}
+	missed := []time.Time{}
+	for t := range Activations(schedule, last, until) {
+		if len(missed) == limit {
+			missed = append(missed[:0], missed[1:]...)
+		}
+		missed = append(missed, t)
+	}
+	return missed
+}
+
+// recordHandled stores the activation time of the iteration, run or skipped on
+// purpose, as the last handled iteration of the job, unless a more recent one
+// is already stored. Only the scheduled iterations of the jobs having a misfire
+// policy are recorded.
+func (c *Cron) recordHandled(ctx context.Context, job Job, it iteration) {
+	client, ok := c.etcdClient()
+	if !ok || it.manual || job.Misfire == MisfireIgnore {
+		return
+	}
+	ctx, cancel := c.detachedContext(ctx)
+	defer cancel()
+
+	key := c.lastHandledKey(job)
+	value := strconv.FormatInt(it.scheduledAt.Unix(), 10)
+	put := etcdclient.OpPut(key, value)
+	// The values are compared as strings, which is right as long as the
+	// timestamps have the same number of digits.
+	_, err := client.Txn(ctx).
+		If(etcdclient.Compare(etcdclient.CreateRevision(key), "=", 0)).
+		Then(put).
+		Else(etcdclient.OpTxn(
+			[]etcdclient.Cmp{etcdclient.Compare(etcdclient.Value(key), "<", value)},
+			[]etcdclient.Op{put}, nil,
+		)).
+		Commit()
+	if err != nil {
+		go c.etcdErrorsHandler(ctx, job, errors.Wrapf(err, "fail to record last handled iteration of job '%v'", job.Name))
+	}
+}
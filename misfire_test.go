@@ -0,0 +1,436 @@
+package etcdcron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	etcdclient "go.etcd.io/etcd/client/v3"
+)
+
+func TestMissedActivations(t *testing.T) {
+	hourly, _ := Parse("0 0 * * * *")
+	at := func(hour int) time.Time {
+		return time.Date(2012, time.July, 9, hour, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		last, until time.Time
+		limit       int
+		expected    []time.Time
+	}{
+		{at(10), at(14), 10, []time.Time{at(11), at(12), at(13)}},
+		{at(10), at(14), 2, []time.Time{at(12), at(13)}},
+		{at(10), at(14), 1, []time.Time{at(13)}},
+		{at(10), at(14), 0, nil},
+		{at(13), at(14), 10, []time.Time{}},
+		{at(14), at(14), 10, []time.Time{}},
+		{at(10).Add(30 * time.Minute), at(12).Add(time.Second), 10, []time.Time{at(11), at(12)}},
+	}
+
+	for _, test := range tests {
+		actual := missedActivations(hourly, test.last, test.until, test.limit)
+		if fmt.Sprint(actual) != fmt.Sprint(test.expected) {
+			t.Errorf("%v - %v (limit %d) => (expected) %v != %v (actual)",
+				test.last, test.until, test.limit, test.expected, actual)
+		}
+	}
+
+	actual := missedActivations(Every(time.Minute), at(12), at(12).Add(90*time.Second), 1)
+	if expected := []time.Time{at(12).Add(time.Minute)}; fmt.Sprint(actual) != fmt.Sprint(expected) {
+		t.Errorf("@every 1m => (expected) %v != %v (actual)", expected, actual)
+	}
+}
+
+type failingMutexBuilder struct {
+	err error
+}
+
+func (b failingMutexBuilder) NewMutex(pfx string) (DistributedMutex, error) {
+	return nil, b.err
+}
+
+// Test that a manual iteration failing because of etcd does not clear the
+// failure of the scheduled iterations, which are caught up once etcd is
+// reachable again.
+func TestMisfireFailedManualIteration(t *testing.T) {
+	cron, err := New(WithEtcdMutexBuilder(failingMutexBuilder{err: errors.New("etcd unreachable")}))
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	job := Job{Name: "test-misfire-manual", Rhythm: "@hourly", Misfire: MisfireRunOnce, Func: func(context.Context) error { return nil }}
+	cron.AddJob(job)
+
+	cron.misfires.setFailed(job, true)
+	err = cron.Trigger(context.Background(), job.Name)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !cron.misfires.setFailed(job, false) {
+		t.Error("expected the job to still be failed")
+	}
+
+	// A failed manual iteration does not mark the job as failed either.
+	err = cron.Trigger(context.Background(), job.Name)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if cron.misfires.setFailed(job, false) {
+		t.Error("expected the job not to be failed")
+	}
+}
+
+// unreachableEtcdBuilder simulates etcd being unreachable while the session of
+// the mutex builder is still alive: the etcd requests fail and locking times
+// out. Otherwise it uses the underlying builder.
+type unreachableEtcdBuilder struct {
+	EtcdMutexBuilder
+	unreachable *etcdclient.Client
+	down        atomic.Bool
+}
+
+func newUnreachableEtcdBuilder(t *testing.T, builder EtcdMutexBuilder) *unreachableEtcdBuilder {
+	client, err := etcdclient.New(etcdclient.Config{Endpoints: []string{"127.0.0.1:1"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	b := &unreachableEtcdBuilder{EtcdMutexBuilder: builder, unreachable: client}
+	b.down.Store(true)
+	return b
+}
+
+func (b *unreachableEtcdBuilder) NewMutex(pfx string) (DistributedMutex, error) {
+	if b.down.Load() {
+		return unreachableMutex{key: pfx}, nil
+	}
+	return b.EtcdMutexBuilder.NewMutex(pfx)
+}
+
+func (b *unreachableEtcdBuilder) EtcdClient() *etcdclient.Client {
+	if b.down.Load() {
+		return b.unreachable
+	}
+	if p, ok := b.EtcdMutexBuilder.(etcdClientProvider); ok {
+		return p.EtcdClient()
+	}
+	return nil
+}
+
+type unreachableMutex struct {
+	key string
+}
+
+func (m unreachableMutex) IsOwner() etcdclient.Cmp {
+	return etcdclient.Compare(etcdclient.CreateRevision(m.key), ">", 0)
+}
+
+func (m unreachableMutex) Key() string {
+	return m.key
+}
+
+func (m unreachableMutex) Lock(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (m unreachableMutex) Unlock(context.Context) error {
+	return nil
+}
+
+// Test that a scheduled iteration is marked as failed when etcd is unreachable
+// while the session is alive, the lock timing out as if another process got
+// it.
+func TestMisfireEtcdUnreachable(t *testing.T) {
+	builder := newUnreachableEtcdBuilder(t, newMemoryMutexBuilder())
+	cron, err := New(WithEtcdMutexBuilder(builder), WithLockTimeout(100*time.Millisecond))
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	job := Job{Name: "test-misfire-unreachable", Rhythm: "* * * * * ?", Misfire: MisfireRunOnce, Func: func(context.Context) error { return nil }}
+	schedule, _ := Parse(job.Rhythm)
+
+	scheduledAt := time.Now().Truncate(time.Second)
+	err = cron.runIteration(context.Background(), job, iteration{
+		lockName:    strconv.FormatInt(scheduledAt.Unix(), 10),
+		scheduledAt: scheduledAt,
+		next:        scheduledAt.Add(time.Second),
+		schedule:    schedule,
+	})
+	if !errors.Is(err, ErrIterationNotRun) {
+		t.Fatalf("expected ErrIterationNotRun, got %v", err)
+	}
+	if !cron.misfires.setFailed(job, false) {
+		t.Error("expected the job to be failed")
+	}
+}
+
+// Test that the iterations missed while etcd was unreachable are caught up by
+// the first iteration run once etcd is reachable again.
+func TestMisfireEtcdRecovery(t *testing.T) {
+	prefix := fmt.Sprintf("etcd_cron_test_misfire_recovery_%d", time.Now().UnixNano())
+	etcdBuilder, err := NewEtcdMutexBuilder(etcdclient.Config{Endpoints: []string{defaultEtcdEndpoint}})
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	defer etcdBuilder.(io.Closer).Close()
+	client := etcdBuilder.(etcdClientProvider).EtcdClient()
+	defer client.Delete(context.Background(), prefix+"/", etcdclient.WithPrefix())
+
+	builder := newUnreachableEtcdBuilder(t, etcdBuilder)
+	scheduled := make(chan time.Time, 10)
+	cron, err := New(WithEtcdMutexBuilder(builder), WithKeyPrefix(prefix), WithLockTimeout(500*time.Millisecond))
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	job := Job{
+		Name:    "test-misfire-recovery",
+		Rhythm:  "* * * * * ?",
+		Misfire: MisfireRunOnce,
+		Func: func(ctx context.Context) error {
+			scheduled <- ScheduledTime(ctx)
+			return nil
+		},
+	}
+	schedule, _ := Parse(job.Rhythm)
+	last := time.Now().Truncate(time.Second).Add(-3 * time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), ONE_SECOND)
+	defer cancel()
+	_, err = client.Put(ctx, cron.lastHandledKey(job), strconv.FormatInt(last.Unix(), 10))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	run := func(scheduledAt time.Time) error {
+		return cron.runIteration(context.Background(), job, iteration{
+			lockName:    strconv.FormatInt(scheduledAt.Unix(), 10),
+			scheduledAt: scheduledAt,
+			next:        scheduledAt.Add(time.Second),
+			schedule:    schedule,
+		})
+	}
+	missed, recovered := last.Add(time.Second), last.Add(2*time.Second)
+	err = run(missed)
+	if !errors.Is(err, ErrIterationNotRun) {
+		t.Fatalf("expected ErrIterationNotRun, got %v", err)
+	}
+
+	builder.down.Store(false)
+	err = run(recovered)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[int64]bool{missed.Unix(): true, recovered.Unix(): true}
+	timeout := time.After(2 * ONE_SECOND)
+	for len(expected) > 0 {
+		select {
+		case <-timeout:
+			t.Fatalf("expected iterations %v to run", expected)
+		case s := <-scheduled:
+			if !expected[s.Unix()] {
+				t.Fatalf("unexpected iteration %v", s)
+			}
+			delete(expected, s.Unix())
+		}
+	}
+}
+
+// Test that the iterations skipped while the job was paused are handled, and
+// not caught up once it is resumed.
+func TestMisfirePaused(t *testing.T) {
+	prefix := fmt.Sprintf("etcd_cron_test_misfire_paused_%d", time.Now().UnixNano())
+	scheduled := make(chan time.Time, 10)
+	cron, err := New(WithKeyPrefix(prefix))
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	defer cron.Close()
+	client, _ := cron.etcdClient()
+	defer client.Delete(context.Background(), prefix+"/", etcdclient.WithPrefix())
+
+	job := Job{
+		Name:    "test-misfire-paused",
+		Rhythm:  "* * * * * ?",
+		Misfire: MisfireRunAll,
+		Func: func(ctx context.Context) error {
+			scheduled <- ScheduledTime(ctx)
+			return nil
+		},
+	}
+	schedule, _ := Parse(job.Rhythm)
+	last := time.Now().Truncate(time.Second).Add(-5 * time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*ONE_SECOND)
+	defer cancel()
+	_, err = client.Put(ctx, cron.lastHandledKey(job), strconv.FormatInt(last.Unix(), 10))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = cron.Pause(ctx, job.Name)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := 1; i <= 2; i++ {
+		scheduledAt := last.Add(time.Duration(i) * time.Second)
+		err = cron.runIteration(context.Background(), job, iteration{
+			lockName:    strconv.FormatInt(scheduledAt.Unix(), 10),
+			scheduledAt: scheduledAt,
+			next:        scheduledAt.Add(time.Second),
+			schedule:    schedule,
+		})
+		if !errors.Is(err, ErrJobPaused) {
+			t.Fatalf("expected ErrJobPaused, got %v", err)
+		}
+	}
+	err = cron.Resume(ctx, job.Name)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The catch-up only runs the iteration missed after the paused ones.
+	missed := last.Add(3 * time.Second)
+	cron.executions.Add(1)
+	cron.catchUp(context.Background(), job, schedule, missed.Add(time.Second), missed.Add(time.Second))
+	select {
+	case s := <-scheduled:
+		if !s.Equal(missed) {
+			t.Errorf("(expected) %v != %v (actual)", missed, s)
+		}
+	default:
+		t.Fatal("expected the missed iteration to run")
+	}
+	if len(scheduled) > 0 {
+		t.Errorf("unexpected iteration %v", <-scheduled)
+	}
+}
+
+func TestMisfirePolicyLimit(t *testing.T) {
+	tests := []struct {
+		job      Job
+		expected int
+	}{
+		{Job{}, 0},
+		{Job{Misfire: MisfireIgnore, MisfireLimit: 5}, 0},
+		{Job{Misfire: MisfireRunOnce, MisfireLimit: 5}, 1},
+		{Job{Misfire: MisfireRunAll}, defaultMisfireLimit},
+		{Job{Misfire: MisfireRunAll, MisfireLimit: 5}, 5},
+	}
+
+	for _, test := range tests {
+		actual := test.job.Misfire.limit(test.job)
+		if actual != test.expected {
+			t.Errorf("%v/%d => (expected) %d != %d (actual)", test.job.Misfire, test.job.MisfireLimit, test.expected, actual)
+		}
+	}
+}
+
+// Test that the iterations missed since the last handled one stored in etcd are
+// run when the cron starts, up to the misfire limit.
+func TestMisfireRunAll(t *testing.T) {
+	prefix := fmt.Sprintf("etcd_cron_test_misfire_%d", time.Now().UnixNano())
+	scheduled := make(chan time.Time, 20)
+	cron, err := New(WithKeyPrefix(prefix))
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	defer cron.Close()
+	client, _ := cron.etcdClient()
+	defer client.Delete(context.Background(), prefix+"/", etcdclient.WithPrefix())
+
+	job := Job{
+		Name:         "test-misfire",
+		Rhythm:       "* * * * * ?",
+		Misfire:      MisfireRunAll,
+		MisfireLimit: 3,
+		Func: func(ctx context.Context) error {
+			scheduled <- ScheduledTime(ctx)
+			return nil
+		},
+	}
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), ONE_SECOND)
+	defer cancel()
+	_, err = client.Put(ctx, cron.lastHandledKey(job), strconv.FormatInt(start.Add(-10*time.Second).Unix(), 10))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cron.AddJob(job)
+	cron.Start(context.Background())
+	defer cron.Stop()
+
+	missed := 0
+	timeout := time.After(2 * ONE_SECOND)
+	for missed < 3 {
+		select {
+		case <-timeout:
+			t.Fatalf("expected 3 missed iterations to run, got %d", missed)
+		case s := <-scheduled:
+			if !s.After(start) {
+				missed++
+			}
+		}
+	}
+	time.Sleep(500 * time.Millisecond)
+	for len(scheduled) > 0 {
+		if s := <-scheduled; !s.After(start) {
+			t.Errorf("unexpected missed iteration %v", s)
+		}
+	}
+}
+
+// Test that the catch-up of an @every job only runs the iterations due before
+// the cron starts, its next activation being computed from the start time.
+func TestMisfireEvery(t *testing.T) {
+	prefix := fmt.Sprintf("etcd_cron_test_misfire_every_%d", time.Now().UnixNano())
+	clock := NewFakeClock(time.Now().Truncate(time.Second))
+	scheduled := make(chan time.Time, 10)
+	cron, err := New(WithKeyPrefix(prefix), WithClock(clock))
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	defer cron.Close()
+	client, _ := cron.etcdClient()
+	defer client.Delete(context.Background(), prefix+"/", etcdclient.WithPrefix())
+
+	job := Job{
+		Name:    "test-misfire-every",
+		Rhythm:  "@every 1m",
+		Misfire: MisfireRunOnce,
+		Func: func(ctx context.Context) error {
+			scheduled <- ScheduledTime(ctx)
+			return nil
+		},
+	}
+	start := clock.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), ONE_SECOND)
+	defer cancel()
+	_, err = client.Put(ctx, cron.lastHandledKey(job), strconv.FormatInt(start.Add(-90*time.Second).Unix(), 10))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cron.AddJob(job)
+	cron.Start(context.Background())
+	defer cron.Stop()
+
+	select {
+	case <-time.After(2 * ONE_SECOND):
+		t.Fatal("expected the missed iteration to run")
+	case s := <-scheduled:
+		if expected := start.Add(-30 * time.Second); !s.Equal(expected) {
+			t.Errorf("(expected) %v != %v (actual)", expected, s)
+		}
+	}
+	select {
+	case <-time.After(500 * time.Millisecond):
+	case s := <-scheduled:
+		t.Errorf("unexpected iteration %v", s)
+	}
+}
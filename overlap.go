@@ -0,0 +1,279 @@
+package etcdcron
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	etcdclient "go.etcd.io/etcd/client/v3"
+)
+
+// OverlapPolicy defines what happens when an iteration of a job is due while
+// a previous iteration is still running, on any node of the cluster.
+type OverlapPolicy int
+
+const (
+	// OverlapAllow runs the iterations concurrently. It is the default policy.
+	OverlapAllow OverlapPolicy = iota
+	// OverlapSkip skips the iteration if a previous one is still running.
+	OverlapSkip
+	// OverlapQueue waits for the previous iteration to finish before running.
+	// At most one iteration is queued, the following ones are skipped.
+	OverlapQueue
+	// OverlapCancelPrevious cancels the context of the running iteration and
+	// waits for it to return before running.
+	OverlapCancelPrevious
+)
+
+func (p OverlapPolicy) String() string {
+	switch p {
+	case OverlapAllow:
+		return "allow"
+	case OverlapSkip:
+		return "skip"
+	case OverlapQueue:
+		return "queue"
+	case OverlapCancelPrevious:
+		return "cancel-previous"
+	}
+	return fmt.Sprintf("OverlapPolicy(%d)", int(p))
+}
+
+// ErrIterationOverlaps is given to the skipped iterations handler when an
+// iteration is skipped because of the overlap policy of its job.
+var ErrIterationOverlaps = errors.New("previous iteration still running")
+
+// overlapState is the local state of the executions of a job, it prevents the
+// iterations running on this node from overlapping before even asking etcd.
+type overlapState struct {
+	// running and queued are semaphores of size 1.
+	running chan struct{}
+	queued  chan struct{}
+	// cancel cancels the running execution (OverlapCancelPrevious).
+	cancel context.CancelFunc
+}
+
+// etcdClientProvider is implemented by the EtcdMutexBuilder giving access to
+// their etcd client. It is required by the features storing data in etcd other
+// than the mutexes.
+type etcdClientProvider interface {
+	EtcdClient() *etcdclient.Client
+}
+
+// etcdClient returns the etcd client of the mutex builder, if any.
+func (c *Cron) etcdClient() (*etcdclient.Client, bool) {
+	p, ok := c.etcdclient.(etcdClientProvider)
+	if !ok || p.EtcdClient() == nil {
+		return nil, false
+	}
+	return p.EtcdClient(), true
+}
+
+func (c *Cron) overlapState(job Job) *overlapState {
+	c.overlapMutex.Lock()
+	defer c.overlapMutex.Unlock()
+
+	if c.overlaps == nil {
+		c.overlaps = map[string]*overlapState{}
+	}
+	state, ok := c.overlaps[job.canonicalName()]
+	if !ok {
+		state = &overlapState{
+			running: make(chan struct{}, 1),
+			queued:  make(chan struct{}, 1),
+		}
+		c.overlaps[job.canonicalName()] = state
+	}
+	return state
+}
+
+// acquireOverlap applies the overlap policy of the job to the iteration
+// scheduled at effective. It returns false if the iteration must be skipped.
+// Otherwise it returns the context of the execution and a function to call once
+// the execution is over.
+func (c *Cron) acquireOverlap(ctx context.Context, job Job, effective time.Time) (context.Context, func(), bool) {
+	if job.Overlap == OverlapAllow {
+		return ctx, func() {}, true
+	}
+
+	state := c.overlapState(job)
+	runningKey := c.jobKey(job, "running")
+
+	switch job.Overlap {
+	case OverlapSkip:
+		select {
+		case state.running <- struct{}{}:
+		default:
+			c.skipIteration(ctx, job, ErrIterationOverlaps)
+			return ctx, nil, false
+		}
+		lockCtx, cancel := context.WithTimeout(ctx, c.lockTimeout)
+		defer cancel()
+		m, ok := c.lockOverlapMutex(lockCtx, job, runningKey)
+		if !ok {
+			<-state.running
+			if lockCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+				c.skipIteration(ctx, job, ErrIterationOverlaps)
+			}
+			return ctx, nil, false
+		}
+		return ctx, func() { c.unlockMutex(ctx, job, m); <-state.running }, true
+
+	case OverlapQueue:
+		select {
+		case state.queued <- struct{}{}:
+		default:
+			c.skipIteration(ctx, job, ErrIterationOverlaps)
+			return ctx, nil, false
+		}
+		defer func() { <-state.queued }()
+
+		lockCtx, cancel := context.WithTimeout(ctx, c.lockTimeout)
+		defer cancel()
+		queued, ok := c.lockOverlapMutex(lockCtx, job, c.jobKey(job, "queued"))
+		if !ok {
+			if lockCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+				c.skipIteration(ctx, job, ErrIterationOverlaps)
+			}
+			return ctx, nil, false
+		}
+		defer c.unlockMutex(ctx, job, queued)
+
+		// The queued iteration gives up waiting once Shutdown is called.
+		waitCtx, cancelWait := c.untilShutdown(ctx)
+		defer cancelWait()
+		select {
+		case state.running <- struct{}{}:
+		case <-waitCtx.Done():
+			if ctx.Err() == nil {
+				c.skipIteration(ctx, job, ErrShuttingDown)
+			}
+			return ctx, nil, false
+		}
+		m, ok := c.lockOverlapMutex(waitCtx, job, runningKey)
+		if !ok {
+			<-state.running
+			if waitCtx.Err() != nil && ctx.Err() == nil {
+				c.skipIteration(ctx, job, ErrShuttingDown)
+			}
+			return ctx, nil, false
+		}
+		return ctx, func() { c.unlockMutex(ctx, job, m); <-state.running }, true
+
+	case OverlapCancelPrevious:
+		c.cancelPrevious(ctx, job, effective, state)
+		select {
+		case state.running <- struct{}{}:
+		case <-ctx.Done():
+			return ctx, nil, false
+		}
+		m, ok := c.lockOverlapMutex(ctx, job, runningKey)
+		if !ok {
+			<-state.running
+			return ctx, nil, false
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		c.overlapMutex.Lock()
+		state.cancel = cancel
+		c.overlapMutex.Unlock()
+		c.watchCancelRequests(ctx, job, effective, cancel)
+
+		return ctx, func() {
+			cancel()
+			c.unlockMutex(ctx, job, m)
+			<-state.running
+		}, true
+	}
+
+	go c.errorsHandler(ctx, job, errors.Errorf("unknown overlap policy %v", job.Overlap))
+	return ctx, nil, false
+}
+
+// lockOverlapMutex locks the mutex with the given key. The context deadline
+// being exceeded means another node holds the lock.
+func (c *Cron) lockOverlapMutex(ctx context.Context, job Job, key string) (DistributedMutex, bool) {
+	m, err := c.etcdclient.NewMutex(key)
+	if err != nil {
+		go c.etcdErrorsHandler(ctx, job, errors.Wrapf(err, "fail to create etcd mutex for job '%v'", job.Name))
+		return nil, false
+	}
+	err = m.Lock(ctx)
+	if err == context.DeadlineExceeded || ctx.Err() != nil {
+		return nil, false
+	} else if err != nil {
+		go c.etcdErrorsHandler(ctx, job, errors.Wrapf(err, "fail to lock mutex '%v'", m.Key()))
+		return nil, false
+	}
+	return m, true
+}
+
+func (c *Cron) unlockMutex(ctx context.Context, job Job, m DistributedMutex) {
+	unlockCtx, cancel := c.detachedContext(ctx)
+	defer cancel()
+	err := m.Unlock(unlockCtx)
+	if err != nil {
+		go c.etcdErrorsHandler(ctx, job, errors.Wrapf(err, "fail to unlock mutex '%v'", m.Key()))
+	}
+}
+
+// cancelPrevious cancels the running execution of the job, whether it runs on
+// this node or on another node of the cluster.
+func (c *Cron) cancelPrevious(ctx context.Context, job Job, effective time.Time, state *overlapState) {
+	c.overlapMutex.Lock()
+	if state.cancel != nil {
+		state.cancel()
+	}
+	c.overlapMutex.Unlock()
+
+	client, ok := c.etcdClient()
+	if !ok {
+		return
+	}
+	_, err := client.Put(ctx, c.jobKey(job, "cancel"), strconv.FormatInt(effective.Unix(), 10))
+	if err != nil {
+		go c.etcdErrorsHandler(ctx, job, errors.Wrapf(err, "fail to request the cancellation of job '%v'", job.Name))
+	}
+}
+
+// watchCancelRequests cancels the execution when an iteration more recent
+// than effective requests its cancellation from another node.
+func (c *Cron) watchCancelRequests(ctx context.Context, job Job, effective time.Time, cancel context.CancelFunc) {
+	client, ok := c.etcdClient()
+	if !ok {
+		return
+	}
+	// Cancellations requested before the watch is started must not be missed.
+	resp, err := client.Get(ctx, c.jobKey(job, "cancel"))
+	if err != nil {
+		go c.etcdErrorsHandler(ctx, job, errors.Wrapf(err, "fail to get cancellation requests of job '%v'", job.Name))
+		return
+	}
+	for _, kv := range resp.Kvs {
+		if isCancelRequested(kv.Value, effective) {
+			cancel()
+			return
+		}
+	}
+
+	go func() {
+		watch := client.Watch(ctx, c.jobKey(job, "cancel"), etcdclient.WithRev(resp.Header.Revision+1))
+		for resp := range watch {
+			for _, event := range resp.Events {
+				if isCancelRequested(event.Kv.Value, effective) {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+}
+
+// isCancelRequested returns true if the value of the cancel key has been set
+// by an iteration scheduled after effective.
+func isCancelRequested(value []byte, effective time.Time) bool {
+	requested, err := strconv.ParseInt(string(value), 10, 64)
+	return err == nil && requested > effective.Unix()
+}
@@ -0,0 +1,259 @@
+package etcdcron
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// newOverlapTestCron returns a started cron using a fake clock and in-memory
+// mutexes, with a single job scheduled every second.
+func newOverlapTestCron(t *testing.T, job Job, skipped chan<- error) (*Cron, *FakeClock) {
+	clock := NewFakeClock(time.Date(2012, time.July, 9, 14, 45, 0, 0, time.Local))
+	cron, err := New(
+		WithClock(clock),
+		WithEtcdMutexBuilder(newMemoryMutexBuilder()),
+		WithShutdownMode(ShutdownCancel),
+		WithSkippedIterationsHandler(func(_ context.Context, _ Job, err error) {
+			skipped <- err
+		}),
+	)
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	job.Rhythm = "@every 1s"
+	err = cron.AddJob(job)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cron.Start(context.Background())
+	return cron, clock
+}
+
+// tick advances the fake clock to the next iteration once the scheduler is
+// sleeping.
+func tick(clock *FakeClock) {
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+}
+
+func TestOverlapSkip(t *testing.T) {
+	started := make(chan time.Time, 10)
+	release := make(chan struct{})
+	skipped := make(chan error, 10)
+
+	cron, clock := newOverlapTestCron(t, Job{
+		Name:    "test-overlap-skip",
+		Overlap: OverlapSkip,
+		Func: func(ctx context.Context) error {
+			started <- ScheduledTime(ctx)
+			<-release
+			return nil
+		},
+	}, skipped)
+
+	tick(clock)
+	select {
+	case <-time.After(ONE_SECOND):
+		t.Fatal("expected job to start")
+	case <-started:
+	}
+
+	tick(clock)
+	select {
+	case <-time.After(ONE_SECOND):
+		t.Fatal("expected iteration to be skipped")
+	case err := <-skipped:
+		if !errors.Is(err, ErrIterationOverlaps) {
+			t.Errorf("unexpected skip reason: %v", err)
+		}
+	}
+
+	close(release)
+	err := cron.Shutdown(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(started) != 0 {
+		t.Errorf("expected the second iteration not to run")
+	}
+}
+
+func TestOverlapQueue(t *testing.T) {
+	started := make(chan time.Time, 10)
+	release := make(chan struct{})
+	skipped := make(chan error, 10)
+
+	job := Job{
+		Name:    "test-overlap-queue",
+		Overlap: OverlapQueue,
+		Func: func(ctx context.Context) error {
+			started <- ScheduledTime(ctx)
+			<-release
+			return nil
+		},
+	}
+	cron, clock := newOverlapTestCron(t, job, skipped)
+	start := clock.Now()
+
+	tick(clock)
+	select {
+	case <-time.After(ONE_SECOND):
+		t.Fatal("expected job to start")
+	case <-started:
+	}
+
+	// The second iteration is queued, wait for it to hold the queue.
+	tick(clock)
+	state := cron.overlapState(job)
+	for len(state.queued) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	tick(clock)
+	select {
+	case <-time.After(ONE_SECOND):
+		t.Fatal("expected third iteration to be skipped")
+	case err := <-skipped:
+		if !errors.Is(err, ErrIterationOverlaps) {
+			t.Errorf("unexpected skip reason: %v", err)
+		}
+	}
+
+	close(release)
+	select {
+	case <-time.After(ONE_SECOND):
+		t.Fatal("expected queued iteration to run")
+	case scheduled := <-started:
+		if !scheduled.Equal(start.Add(2 * time.Second)) {
+			t.Errorf("expected the second iteration to run, got the one scheduled at %v", scheduled)
+		}
+	}
+
+	err := cron.Shutdown(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Test that a queued iteration is not run once Shutdown has been called, even
+// if Shutdown waits for the running one.
+func TestOverlapQueueShutdown(t *testing.T) {
+	started := make(chan time.Time, 10)
+	release := make(chan struct{})
+	skipped := make(chan error, 10)
+
+	clock := NewFakeClock(time.Date(2012, time.July, 9, 14, 45, 0, 0, time.Local))
+	cron, err := New(
+		WithClock(clock),
+		WithEtcdMutexBuilder(newMemoryMutexBuilder()),
+		WithSkippedIterationsHandler(func(_ context.Context, _ Job, err error) {
+			skipped <- err
+		}),
+	)
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	job := Job{
+		Name:    "test-overlap-queue-shutdown",
+		Rhythm:  "@every 1s",
+		Overlap: OverlapQueue,
+		Func: func(ctx context.Context) error {
+			started <- ScheduledTime(ctx)
+			<-release
+			return nil
+		},
+	}
+	cron.AddJob(job)
+	cron.Start(context.Background())
+
+	tick(clock)
+	select {
+	case <-time.After(ONE_SECOND):
+		t.Fatal("expected job to start")
+	case <-started:
+	}
+
+	// The second iteration is queued, wait for it to hold the queue.
+	tick(clock)
+	state := cron.overlapState(job)
+	for len(state.queued) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	shutdown := make(chan error)
+	go func() { shutdown <- cron.Shutdown(context.Background()) }()
+	select {
+	case <-time.After(ONE_SECOND):
+		t.Fatal("expected queued iteration to be skipped")
+	case err := <-skipped:
+		if !errors.Is(err, ErrShuttingDown) {
+			t.Errorf("unexpected skip reason: %v", err)
+		}
+	}
+
+	close(release)
+	select {
+	case <-time.After(ONE_SECOND):
+		t.Fatal("expected Shutdown to return")
+	case err := <-shutdown:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if len(started) != 0 {
+		t.Errorf("expected the queued iteration not to run")
+	}
+}
+
+func TestOverlapCancelPrevious(t *testing.T) {
+	started := make(chan time.Time, 10)
+	canceled := make(chan time.Time, 10)
+	skipped := make(chan error, 10)
+
+	cron, clock := newOverlapTestCron(t, Job{
+		Name:    "test-overlap-cancel-previous",
+		Overlap: OverlapCancelPrevious,
+		Func: func(ctx context.Context) error {
+			started <- ScheduledTime(ctx)
+			<-ctx.Done()
+			canceled <- ScheduledTime(ctx)
+			return nil
+		},
+	}, skipped)
+
+	tick(clock)
+	var first time.Time
+	select {
+	case <-time.After(ONE_SECOND):
+		t.Fatal("expected job to start")
+	case first = <-started:
+	}
+
+	tick(clock)
+	select {
+	case <-time.After(ONE_SECOND):
+		t.Fatal("expected first iteration to be canceled")
+	case scheduled := <-canceled:
+		if !scheduled.Equal(first) {
+			t.Errorf("expected the first iteration to be canceled, got the one scheduled at %v", scheduled)
+		}
+	}
+	select {
+	case <-time.After(ONE_SECOND):
+		t.Fatal("expected second iteration to start")
+	case scheduled := <-started:
+		if !scheduled.Equal(first.Add(time.Second)) {
+			t.Errorf("expected the second iteration to start, got the one scheduled at %v", scheduled)
+		}
+	}
+	if len(skipped) != 0 {
+		t.Errorf("expected no iteration to be skipped")
+	}
+
+	err := cron.Shutdown(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -2,91 +2,539 @@ package etcdcron
 
 import (
 	"fmt"
-	"log"
+	"hash/fnv"
 	"math"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// ParseError is returned by Parse when the spec is not valid.
+type ParseError struct {
+	// Spec being parsed
+	Spec string
+	// Name of the invalid field (ie. "minute", "day of week", "time zone" or
+	// "descriptor"), empty if the error is not related to a single field
+	Field string
+	// Byte offset of the offending token in the spec
+	Position int
+	// Offending token
+	Token string
+	// Description of the error
+	Reason string
+	// Underlying error, if any
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	reason := e.Reason
+	if e.Err != nil {
+		reason = fmt.Sprintf("%s: %v", reason, e.Err)
+	}
+	if e.Field == "" {
+		return fmt.Sprintf("invalid spec %q: %s", e.Spec, reason)
+	}
+	return fmt.Sprintf("invalid %s %q at position %d of spec %q: %s", e.Field, e.Token, e.Position, e.Spec, reason)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// token is a part of the spec, and its byte offset in the spec.
+type token struct {
+	value string
+	pos   int
+}
+
+// splitTokens splits the token around each rune satisfying isSeparator, the
+// empty tokens are dropped.
+func splitTokens(t token, isSeparator func(rune) bool) []token {
+	tokens := []token{}
+	start := -1
+	for i, r := range t.value {
+		if isSeparator(r) {
+			if start >= 0 {
+				tokens = append(tokens, token{t.value[start:i], t.pos + start})
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{t.value[start:], t.pos + start})
+	}
+	return tokens
+}
+
+// ParseOption configures the fields accepted by a Parser.
+type ParseOption int
+
+const (
+	// Seconds field
+	Second ParseOption = 1 << iota
+	// Optional seconds field, 0 if omitted
+	SecondOptional
+	// Minutes field
+	Minute
+	// Hours field
+	Hour
+	// Day of month field
+	Dom
+	// Month field
+	Month
+	// Day of week field
+	Dow
+	// Optional day of week field, * if omitted
+	DowOptional
+	// Descriptors, e.g. "@midnight", "@every 1h30m"
+	Descriptor
+	// Year field
+	Year
+	// Optional year field, * if omitted
+	YearOptional
 )
 
+// optionalFields are the options of the optional fields.
+const optionalFields = SecondOptional | DowOptional | YearOptional
+
+// modifier parses the expression into the schedule if it's a modifier of the
+// field. It returns false if the expression is not a modifier.
+type modifier func(expr token, s *SpecSchedule) (bool, *ParseError)
+
+// specField is the name, option, bounds, default value and modifier of a
+// field of a spec.
+type specField struct {
+	name         string
+	option       ParseOption
+	bounds       bounds
+	defaultValue string
+	modifier     modifier
+}
+
+// specFields are the fields of a spec, in order.
+var specFields = []specField{
+	{"second", Second | SecondOptional, seconds, "0", nil},
+	{"minute", Minute, minutes, "0", nil},
+	{"hour", Hour, hours, "0", nil},
+	{"day of month", Dom, dom, "*", parseDomModifier},
+	{"month", Month, months, "*", nil},
+	{"day of week", Dow | DowOptional, dow, "*", parseDowModifier},
+	{"year", Year | YearOptional, years, "*", nil},
+}
+
+// Parser parses the specs of the dialect defined by its options. The fields
+// which are not part of the dialect take their default value: 0 for the
+// seconds, minutes and hours, * for the other fields.
+type Parser struct {
+	options ParseOption
+	hashKey string
+}
+
+// WithHashKey returns a copy of the parser resolving the H tokens of the specs
+// from the given key. The cron uses the canonical name of the job as key, so
+// that the jobs having the same spec are spread out, while each of them gets
+// the same activation times on all the nodes.
+//
+// The H tokens are only accepted by a parser having a hash key:
+//   - "H" is a value of the field, e.g. "0 H * * * *" is hourly at some minute
+//   - "H(0-29)" is a value in the given range
+//   - "H/15" and "H(0-29)/10" are steps starting at a hashed offset
+//
+// In the day of month field, H is a value between 1 and 28 so that it exists
+// in every month.
+func (p Parser) WithHashKey(key string) Parser {
+	p.hashKey = key
+	return p
+}
+
+// NewParser returns a Parser accepting the given fields, ie. the standard
+// crontab dialect:
+//
+//	NewParser(Minute | Hour | Dom | Month | Dow | Descriptor)
+//
+// It returns an error if the options accept neither field nor descriptor, or
+// define an optional seconds field along with another optional field. The
+// optional day of week and year fields can be combined, the year being omitted
+// first.
+func NewParser(options ParseOption) (Parser, error) {
+	if options&SecondOptional > 0 && options&(DowOptional|YearOptional) > 0 {
+		return Parser{}, errors.New("optional seconds can't be combined with another optional field")
+	}
+	if options == 0 {
+		return Parser{}, errors.New("neither field nor descriptor accepted")
+	}
+	return Parser{options: options}, nil
+}
+
+// defaultParser is the dialect of Parse.
+var defaultParser = Parser{
+	options: Second | Minute | Hour | Dom | Month | DowOptional | YearOptional | Descriptor,
+}
+
 // Parse returns a new crontab schedule representing the given spec.
-// It returns a descriptive error if the spec is not valid.
+// It returns a *ParseError if the spec is not valid.
 //
 // It accepts
-//   - Full crontab specs, e.g. "* * * * * ?"
+//   - Full crontab specs, with seconds, an optional day of week and an optional
+//     year, e.g. "* * * * * ?" or "0 0 9 ? * Mon 2027"
 //   - Descriptors, e.g. "@midnight", "@every 1h30m"
-func Parse(spec string) (_ Schedule, err error) {
-	// Convert panics into errors
-	defer func() {
-		if recovered := recover(); recovered != nil {
-			err = fmt.Errorf("%v", recovered)
-		}
-	}()
+//
+// Both can be prefixed with the time zone in which they are evaluated, e.g.
+// "CRON_TZ=Europe/Paris 0 0 9 * * *" or "TZ=UTC @daily".
+func Parse(spec string) (Schedule, error) {
+	return defaultParser.Parse(spec)
+}
 
-	if spec[0] == '@' {
-		return parseDescriptor(spec), nil
+// Parse returns a new crontab schedule representing the given spec, in the
+// dialect of the parser. It returns a *ParseError if the spec is not valid.
+//
+// Like with the Parse function, the spec can be prefixed with a time zone.
+func (p Parser) Parse(spec string) (Schedule, error) {
+	schedule, err := p.parse(spec)
+	if err != nil {
+		err.Spec = spec
+		return nil, err
 	}
+	return schedule, nil
+}
 
-	// Split on whitespace.  We require 5 or 6 fields.
-	// (second) (minute) (hour) (day of month) (month) (day of week, optional)
-	fields := strings.Fields(spec)
-	if len(fields) != 5 && len(fields) != 6 {
-		log.Panicf("Expected 5 or 6 fields, found %d: %s", len(fields), spec)
+func (p Parser) parse(spec string) (Schedule, *ParseError) {
+	location, rest, err := parseLocation(token{spec, 0})
+	if err != nil {
+		return nil, err
+	}
+	if rest.value == "" {
+		return nil, &ParseError{Position: rest.pos, Reason: "empty spec"}
 	}
 
-	// If a sixth field is not provided (DayOfWeek), then it is equivalent to star.
-	if len(fields) == 5 {
-		fields = append(fields, "*")
+	if rest.value[0] == '@' {
+		if p.options&Descriptor == 0 {
+			return nil, &ParseError{
+				Field: "descriptor", Position: rest.pos, Token: rest.value,
+				Reason: "descriptors are not accepted",
+			}
+		}
+		schedule, err := parseDescriptor(rest)
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := schedule.(*SpecSchedule); ok {
+			s.Location = location
+		}
+		return schedule, nil
 	}
 
-	schedule := &SpecSchedule{
-		Second: getField(fields[0], seconds),
-		Minute: getField(fields[1], minutes),
-		Hour:   getField(fields[2], hours),
-		Dom:    getField(fields[3], dom),
-		Month:  getField(fields[4], months),
-		Dow:    getField(fields[5], dow),
+	fields, err := p.normalizeFields(splitTokens(rest, unicode.IsSpace), rest, len(spec))
+	if err != nil {
+		return nil, err
 	}
 
+	schedule := &SpecSchedule{Location: location}
+	bits := make([]uint64, len(fields))
+	for i, field := range fields {
+		var err *ParseError
+		if specFields[i].option&Year > 0 {
+			schedule.Year, err = getYears(field)
+		} else {
+			bits[i], err = parseField(field, specFields[i], schedule, p.hashKey)
+		}
+		if err != nil {
+			err.Field = specFields[i].name
+			return nil, err
+		}
+	}
+	schedule.Second = bits[0]
+	schedule.Minute = bits[1]
+	schedule.Hour = bits[2]
+	schedule.Dom = bits[3]
+	schedule.Month = bits[4]
+	schedule.Dow = bits[5]
+
 	return schedule, nil
 }
 
+// normalizeFields returns all the fields of the spec, from the fields of the
+// dialect of the parser, the missing ones taking their default value at the
+// given end position. The optional fields are omitted from the last one.
+func (p Parser) normalizeFields(fields []token, spec token, end int) ([]token, *ParseError) {
+	max := 0
+	for _, f := range specFields {
+		if p.options&f.option > 0 {
+			max++
+		}
+	}
+	if max == 0 {
+		return nil, &ParseError{
+			Position: spec.pos, Token: spec.value,
+			Reason: "only descriptors are accepted",
+		}
+	}
+	optional := 0
+	for _, f := range specFields {
+		if p.options&f.option&optionalFields > 0 {
+			optional++
+		}
+	}
+	min := max - optional
+	if len(fields) < min || len(fields) > max {
+		expected := strconv.Itoa(max)
+		switch max - min {
+		case 0:
+		case 1:
+			expected = fmt.Sprintf("%d or %d", min, max)
+		default:
+			expected = fmt.Sprintf("%d to %d", min, max)
+		}
+		return nil, &ParseError{
+			Position: spec.pos, Token: spec.value,
+			Reason: fmt.Sprintf("expected %s fields, found %d", expected, len(fields)),
+		}
+	}
+	// The number of optional fields given
+	given := optional - (max - len(fields))
+
+	normalized := make([]token, 0, len(specFields))
+	for _, f := range specFields {
+		omitted := false
+		if p.options&f.option&optionalFields > 0 {
+			omitted = given == 0
+			if !omitted {
+				given--
+			}
+		}
+		if p.options&f.option == 0 || omitted {
+			normalized = append(normalized, token{f.defaultValue, end})
+			continue
+		}
+		normalized = append(normalized, fields[0])
+		fields = fields[1:]
+	}
+	return normalized, nil
+}
+
+// parseLocation extracts the CRON_TZ= or TZ= prefix of the spec. It returns the
+// location, nil if the spec has no prefix, and the rest of the spec. The time
+// zone must be named explicitly: the local time zone depends on the host, the
+// nodes would not compute the same activation times.
+func parseLocation(spec token) (*time.Location, token, *ParseError) {
+	trimmed := strings.TrimLeftFunc(spec.value, unicode.IsSpace)
+	spec = token{strings.TrimRightFunc(trimmed, unicode.IsSpace), spec.pos + len(spec.value) - len(trimmed)}
+
+	var name token
+	found := false
+	for _, prefix := range []string{"CRON_TZ=", "TZ="} {
+		if strings.HasPrefix(spec.value, prefix) {
+			name = token{strings.TrimPrefix(spec.value, prefix), spec.pos + len(prefix)}
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, spec, nil
+	}
+
+	zone := name.value
+	i := strings.IndexFunc(name.value, unicode.IsSpace)
+	if i != -1 {
+		zone = name.value[:i]
+	}
+	switch {
+	case zone == "":
+		return nil, token{}, &ParseError{
+			Field: "time zone", Position: name.pos, Token: zone,
+			Reason: "missing time zone",
+		}
+	case zone == "Local":
+		return nil, token{}, &ParseError{
+			Field: "time zone", Position: name.pos, Token: zone,
+			Reason: "the local time zone depends on the host",
+		}
+	case i == -1:
+		return nil, token{}, &ParseError{
+			Field: "time zone", Position: name.pos, Token: name.value,
+			Reason: "missing schedule after time zone",
+		}
+	}
+	location, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, token{}, &ParseError{
+			Field: "time zone", Position: name.pos, Token: name.value[:i],
+			Reason: "failed to load time zone", Err: err,
+		}
+	}
+	rest := strings.TrimLeftFunc(name.value[i:], unicode.IsSpace)
+	return location, token{rest, name.pos + len(name.value) - len(rest)}, nil
+}
+
 // getField returns an Int with the bits set representing all of the times that
 // the field represents.  A "field" is a comma-separated list of "ranges".
-func getField(field string, r bounds) uint64 {
-	// list = range {"," range}
+func getField(field token, r bounds) (uint64, *ParseError) {
+	return parseField(field, specField{bounds: r}, nil, "")
+}
+
+// parseField is getField for a field of a spec, accepting the modifiers of the
+// field, which are parsed into the schedule, and the H tokens, resolved from the
+// hash key.
+func parseField(field token, f specField, s *SpecSchedule, hashKey string) (uint64, *ParseError) {
+	// list = (range | modifier | hash) {"," (range | modifier | hash)}
 	var bits uint64
-	ranges := strings.FieldsFunc(field, func(r rune) bool { return r == ',' })
+	ranges := splitTokens(field, func(r rune) bool { return r == ',' })
 	for _, expr := range ranges {
-		bits |= getRange(expr, r)
+		if f.modifier != nil {
+			ok, err := f.modifier(expr, s)
+			if err != nil {
+				return 0, err
+			}
+			if ok {
+				continue
+			}
+		}
+		if strings.HasPrefix(expr.value, "H") {
+			b, err := getHashedRange(expr, f, hashKey)
+			if err != nil {
+				return 0, err
+			}
+			bits |= b
+			continue
+		}
+		b, err := getRange(expr, f.bounds)
+		if err != nil {
+			return 0, err
+		}
+		bits |= b
 	}
-	return bits
+	return bits, nil
 }
 
 // getRange returns the bits indicated by the given expression:
-//   number | number "-" number [ "/" number ]
-func getRange(expr string, r bounds) uint64 {
+//
+//	number | number "-" number [ "/" number ]
+func getRange(expr token, r bounds) (uint64, *ParseError) {
+	start, end, step, star, err := parseRange(expr, r)
+	if err != nil {
+		return 0, err
+	}
+	var extra_star uint64
+	if star {
+		extra_star = starBit
+	}
+	return getBits(start, end, step) | extra_star, nil
+}
+
+// getHashedRange returns the bits indicated by the given H expression, resolved
+// from the hash of the key and the field name:
+//
+//	"H" [ "(" number "-" number ")" ] [ "/" number ]
+func getHashedRange(expr token, f specField, hashKey string) (uint64, *ParseError) {
+	invalid := func(reason string, err error) (uint64, *ParseError) {
+		return 0, &ParseError{Position: expr.pos, Token: expr.value, Reason: reason, Err: err}
+	}
+	if hashKey == "" {
+		return invalid("H requires a hash key, see Parser.WithHashKey", nil)
+	}
+
+	start, end := f.bounds.min, f.bounds.max
+	if f.option&Dom > 0 {
+		// Days existing in every month
+		end = 28
+	}
+	rest := strings.TrimPrefix(expr.value, "H")
+	if strings.HasPrefix(rest, "(") {
+		i := strings.Index(rest, ")")
+		if i == -1 {
+			return invalid("missing closing parenthesis", nil)
+		}
+		hashRange := token{rest[1:i], expr.pos + 2}
+		var err *ParseError
+		var step uint
+		start, end, step, _, err = parseRange(hashRange, f.bounds)
+		if err != nil {
+			err.Position, err.Token = expr.pos, expr.value
+			return 0, err
+		}
+		if step != 1 || start == end {
+			return invalid("hash range must be a range without step", nil)
+		}
+		rest = rest[i+1:]
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(hashKey + "/" + f.name))
+	hash := uint(h.Sum32())
+
+	switch {
+	case rest == "":
+		return 1 << (start + hash%(end-start+1)), nil
+	case strings.HasPrefix(rest, "/"):
+		step, err := parseInt(rest[1:])
+		if err != nil {
+			return invalid("invalid step", err)
+		}
+		if step == 0 {
+			return invalid("step must be positive", nil)
+		}
+		return getBits(start+hash%min(step, end-start+1), end, step), nil
+	}
+	return invalid("invalid hash expression", nil)
+}
+
+// getYears returns the bit set of the years indicated by the given field, nil
+// if the field is a star.
+func getYears(field token) ([]uint64, *ParseError) {
+	bits := make([]uint64, (years.max-years.min)/64+1)
+	for _, expr := range splitTokens(field, func(r rune) bool { return r == ',' }) {
+		start, end, step, star, err := parseRange(expr, years)
+		if err != nil {
+			return nil, err
+		}
+		if star && step == 1 {
+			return nil, nil
+		}
+		for year := start; year <= end; year += step {
+			i := year - years.min
+			bits[i/64] |= 1 << (i % 64)
+		}
+	}
+	return bits, nil
+}
+
+// parseRange returns the beginning, end and step of the given expression, and
+// whether it's a star.
+func parseRange(expr token, r bounds) (start, end, step uint, star bool, perr *ParseError) {
 	var (
-		start, end, step uint
-		rangeAndStep     = strings.Split(expr, "/")
-		lowAndHigh       = strings.Split(rangeAndStep[0], "-")
-		singleDigit      = len(lowAndHigh) == 1
+		rangeAndStep = strings.Split(expr.value, "/")
+		lowAndHigh   = strings.Split(rangeAndStep[0], "-")
+		singleDigit  = len(lowAndHigh) == 1
+		err          error
 	)
+	invalid := func(reason string, err error) (uint, uint, uint, bool, *ParseError) {
+		return 0, 0, 0, false, &ParseError{Position: expr.pos, Token: expr.value, Reason: reason, Err: err}
+	}
 
-	var extra_star uint64
 	if lowAndHigh[0] == "*" || lowAndHigh[0] == "?" {
 		start = r.min
 		end = r.max
-		extra_star = starBit
+		star = true
 	} else {
-		start = parseIntOrName(lowAndHigh[0], r.names)
+		start, err = parseIntOrName(lowAndHigh[0], r.names)
+		if err != nil {
+			return invalid("invalid beginning of range", err)
+		}
 		switch len(lowAndHigh) {
 		case 1:
 			end = start
 		case 2:
-			end = parseIntOrName(lowAndHigh[1], r.names)
+			end, err = parseIntOrName(lowAndHigh[1], r.names)
+			if err != nil {
+				return invalid("invalid end of range", err)
+			}
 		default:
-			log.Panicf("Too many hyphens: %s", expr)
+			return invalid("too many hyphens", nil)
 		}
 	}
 
@@ -94,50 +542,128 @@ func getRange(expr string, r bounds) uint64 {
 	case 1:
 		step = 1
 	case 2:
-		step = mustParseInt(rangeAndStep[1])
+		step, err = parseInt(rangeAndStep[1])
+		if err != nil {
+			return invalid("invalid step", err)
+		}
+		if step == 0 {
+			return invalid("step must be positive", nil)
+		}
 
 		// Special handling: "N/step" means "N-max/step".
 		if singleDigit {
 			end = r.max
 		}
 	default:
-		log.Panicf("Too many slashes: %s", expr)
+		return invalid("too many slashes", nil)
 	}
 
 	if start < r.min {
-		log.Panicf("Beginning of range (%d) below minimum (%d): %s", start, r.min, expr)
+		return invalid(fmt.Sprintf("beginning of range (%d) below minimum (%d)", start, r.min), nil)
 	}
 	if end > r.max {
-		log.Panicf("End of range (%d) above maximum (%d): %s", end, r.max, expr)
+		return invalid(fmt.Sprintf("end of range (%d) above maximum (%d)", end, r.max), nil)
 	}
 	if start > end {
-		log.Panicf("Beginning of range (%d) beyond end of range (%d): %s", start, end, expr)
+		return invalid(fmt.Sprintf("beginning of range (%d) beyond end of range (%d)", start, end), nil)
+	}
+
+	return start, end, step, star, nil
+}
+
+// parseDomModifier parses the Quartz-style modifiers of the day of month field:
+//
+//	"L" | "LW" | number "W"
+func parseDomModifier(expr token, s *SpecSchedule) (bool, *ParseError) {
+	value := strings.ToUpper(expr.value)
+	switch {
+	case value == "L":
+		s.LastDay = true
+	case value == "LW":
+		s.LastWeekday = true
+	case strings.HasSuffix(value, "W"):
+		day, err := parseInt(value[:len(value)-1])
+		if err != nil {
+			return false, &ParseError{Position: expr.pos, Token: expr.value, Reason: "invalid day of nearest weekday", Err: err}
+		}
+		if day < dom.min || day > dom.max {
+			return false, &ParseError{
+				Position: expr.pos, Token: expr.value,
+				Reason: fmt.Sprintf("day of nearest weekday (%d) out of range [%d, %d]", day, dom.min, dom.max),
+			}
+		}
+		s.NearestWeekday |= 1 << day
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// maxNthDow is the maximum occurrence in a month of a day of week.
+const maxNthDow = 5
+
+// parseDowModifier parses the Quartz-style modifiers of the day of week field:
+//
+//	number "L" | number "#" number
+func parseDowModifier(expr token, s *SpecSchedule) (bool, *ParseError) {
+	invalid := func(reason string, err error) (bool, *ParseError) {
+		return false, &ParseError{Position: expr.pos, Token: expr.value, Reason: reason, Err: err}
+	}
+
+	if weekday, nth, found := strings.Cut(expr.value, "#"); found {
+		day, err := parseIntOrName(weekday, dow.names)
+		if err != nil {
+			return invalid("invalid day of week", err)
+		}
+		if day > dow.max {
+			return invalid(fmt.Sprintf("day of week (%d) above maximum (%d)", day, dow.max), nil)
+		}
+		n, err := parseInt(nth)
+		if err != nil {
+			return invalid("invalid occurrence", err)
+		}
+		if n < 1 || n > maxNthDow {
+			return invalid(fmt.Sprintf("occurrence (%d) out of range [1, %d]", n, maxNthDow), nil)
+		}
+		s.NthDow |= 1 << (7*(n-1) + day)
+		return true, nil
 	}
 
-	return getBits(start, end, step) | extra_star
+	if len(expr.value) > 1 && strings.ToUpper(expr.value[len(expr.value)-1:]) == "L" {
+		day, err := parseIntOrName(expr.value[:len(expr.value)-1], dow.names)
+		if err != nil {
+			return invalid("invalid day of week", err)
+		}
+		if day > dow.max {
+			return invalid(fmt.Sprintf("day of week (%d) above maximum (%d)", day, dow.max), nil)
+		}
+		s.LastDow |= 1 << day
+		return true, nil
+	}
+	return false, nil
 }
 
 // parseIntOrName returns the (possibly-named) integer contained in expr.
-func parseIntOrName(expr string, names map[string]uint) uint {
+func parseIntOrName(expr string, names map[string]uint) (uint, error) {
 	if names != nil {
 		if namedInt, ok := names[strings.ToLower(expr)]; ok {
-			return namedInt
+			return namedInt, nil
 		}
 	}
-	return mustParseInt(expr)
+	return parseInt(expr)
 }
 
-// mustParseInt parses the given expression as an int or panics.
-func mustParseInt(expr string) uint {
+// parseInt parses the given expression as a positive int.
+func parseInt(expr string) (uint, error) {
 	num, err := strconv.Atoi(expr)
 	if err != nil {
-		log.Panicf("Failed to parse int from %s: %s", expr, err)
+		return 0, fmt.Errorf("failed to parse int from %s: %w", expr, err)
 	}
 	if num < 0 {
-		log.Panicf("Negative number (%d) not allowed: %s", num, expr)
+		return 0, fmt.Errorf("negative number (%d) not allowed: %s", num, expr)
 	}
 
-	return uint(num)
+	return uint(num), nil
 }
 
 // getBits sets all bits in the range [min, max], modulo the given step size.
@@ -161,9 +687,10 @@ func all(r bounds) uint64 {
 	return getBits(r.min, r.max, 1) | starBit
 }
 
-// parseDescriptor returns a pre-defined schedule for the expression, or panics
-// if none matches.
-func parseDescriptor(spec string) Schedule {
+// parseDescriptor returns a pre-defined schedule for the expression, or an
+// error if none matches.
+func parseDescriptor(descriptor token) (Schedule, *ParseError) {
+	spec := descriptor.value
 	switch spec {
 	case "@yearly", "@annually":
 		return &SpecSchedule{
@@ -173,7 +700,7 @@ func parseDescriptor(spec string) Schedule {
 			Dom:    1 << dom.min,
 			Month:  1 << months.min,
 			Dow:    all(dow),
-		}
+		}, nil
 
 	case "@monthly":
 		return &SpecSchedule{
@@ -183,7 +710,7 @@ func parseDescriptor(spec string) Schedule {
 			Dom:    1 << dom.min,
 			Month:  all(months),
 			Dow:    all(dow),
-		}
+		}, nil
 
 	case "@weekly":
 		return &SpecSchedule{
@@ -193,7 +720,7 @@ func parseDescriptor(spec string) Schedule {
 			Dom:    all(dom),
 			Month:  all(months),
 			Dow:    1 << dow.min,
-		}
+		}, nil
 
 	case "@daily", "@midnight":
 		return &SpecSchedule{
@@ -203,7 +730,7 @@ func parseDescriptor(spec string) Schedule {
 			Dom:    all(dom),
 			Month:  all(months),
 			Dow:    all(dow),
-		}
+		}, nil
 
 	case "@hourly":
 		return &SpecSchedule{
@@ -213,18 +740,23 @@ func parseDescriptor(spec string) Schedule {
 			Dom:    all(dom),
 			Month:  all(months),
 			Dow:    all(dow),
-		}
+		}, nil
 	}
 
 	const every = "@every "
 	if strings.HasPrefix(spec, every) {
-		duration, err := time.ParseDuration(spec[len(every):])
+		duration, err := time.ParseDuration(strings.TrimSpace(spec[len(every):]))
 		if err != nil {
-			log.Panicf("Failed to parse duration %s: %s", spec, err)
+			return nil, &ParseError{
+				Field: "descriptor", Position: descriptor.pos, Token: spec,
+				Reason: "failed to parse duration", Err: err,
+			}
 		}
-		return Every(duration)
+		return Every(duration), nil
 	}
 
-	log.Panicf("Unrecognized descriptor: %s", spec)
-	return nil
+	return nil, &ParseError{
+		Field: "descriptor", Position: descriptor.pos, Token: spec,
+		Reason: "unrecognized descriptor",
+	}
 }
@@ -1,6 +1,9 @@
 package etcdcron
 
 import (
+	"errors"
+	"fmt"
+	"math/bits"
 	"reflect"
 	"testing"
 	"time"
@@ -29,7 +32,10 @@ func TestRange(t *testing.T) {
 	}
 
 	for _, c := range ranges {
-		actual := getRange(c.expr, bounds{c.min, c.max, nil})
+		actual, err := getRange(token{c.expr, 0}, bounds{c.min, c.max, nil})
+		if err != nil {
+			t.Errorf("%s => unexpected error %v", c.expr, err)
+		}
 		if actual != c.expected {
 			t.Errorf("%s => (expected) %d != %d (actual)", c.expr, c.expected, actual)
 		}
@@ -49,7 +55,10 @@ func TestField(t *testing.T) {
 	}
 
 	for _, c := range fields {
-		actual := getField(c.expr, bounds{c.min, c.max, nil})
+		actual, err := getField(token{c.expr, 0}, bounds{c.min, c.max, nil})
+		if err != nil {
+			t.Errorf("%s => unexpected error %v", c.expr, err)
+		}
 		if actual != c.expected {
 			t.Errorf("%s => (expected) %d != %d (actual)", c.expr, c.expected, actual)
 		}
@@ -101,8 +110,15 @@ func TestSpecSchedule(t *testing.T) {
 		expr     string
 		expected Schedule
 	}{
-		{"* 5 * * * *", &SpecSchedule{all(seconds), 1 << 5, all(hours), all(dom), all(months), all(dow)}},
+		{"* 5 * * * *", &SpecSchedule{Second: all(seconds), Minute: 1 << 5, Hour: all(hours), Dom: all(dom), Month: all(months), Dow: all(dow)}},
 		{"@every 5m", ConstantDelaySchedule{time.Duration(5) * time.Minute}},
+		{"CRON_TZ=UTC * 5 * * * *", &SpecSchedule{Second: all(seconds), Minute: 1 << 5, Hour: all(hours), Dom: all(dom), Month: all(months), Dow: all(dow), Location: time.UTC}},
+		{"TZ=UTC  @hourly", &SpecSchedule{Second: 1 << seconds.min, Minute: 1 << minutes.min, Hour: all(hours), Dom: all(dom), Month: all(months), Dow: all(dow), Location: time.UTC}},
+		{"TZ=UTC @every 5m", ConstantDelaySchedule{time.Duration(5) * time.Minute}},
+		{"0 0 0 L,LW,15W * ?", &SpecSchedule{Second: 1 << 0, Minute: 1 << 0, Hour: 1 << 0, Month: all(months), Dow: all(dow), LastDay: true, LastWeekday: true, NearestWeekday: 1 << 15}},
+		{"0 0 0 1 Jan ? *", &SpecSchedule{Second: 1 << 0, Minute: 1 << 0, Hour: 1 << 0, Dom: 1 << 1, Month: 1 << 1, Dow: all(dow)}},
+		{"0 0 0 1 Jan ? 2027,2033-2037/2", &SpecSchedule{Second: 1 << 0, Minute: 1 << 0, Hour: 1 << 0, Dom: 1 << 1, Month: 1 << 1, Dow: all(dow), Year: []uint64{1<<57 | 1<<63, 1<<1 | 1<<3, 0}}},
+		{"0 0 0 ? * 5L,2#2,Sun#5", &SpecSchedule{Second: 1 << 0, Minute: 1 << 0, Hour: 1 << 0, Dom: all(dom), Month: all(months), LastDow: 1 << 5, NthDow: 1<<(7+2) | 1<<(7*4)}},
 	}
 
 	for _, c := range entries {
@@ -115,3 +131,201 @@ func TestSpecSchedule(t *testing.T) {
 		}
 	}
 }
+
+func TestParseError(t *testing.T) {
+	entries := []struct {
+		expr     string
+		field    string
+		position int
+		token    string
+	}{
+		{"", "", 0, ""},
+		{"   ", "", 3, ""},
+		{"* * * *", "", 0, "* * * *"},
+		{"0 60 * * *", "minute", 2, "60"},
+		{"0 0 1,25-30 * *", "hour", 6, "25-30"},
+		{"0 0 * 0 *", "day of month", 6, "0"},
+		{"0 0 * * XYZ", "month", 8, "XYZ"},
+		{"0 0 * * * Mon-Foo", "day of week", 10, "Mon-Foo"},
+		{"0 */0 * * *", "minute", 2, "*/0"},
+		{"0 1/2/3 * * *", "minute", 2, "1/2/3"},
+		{"0 1-2-3 * * *", "minute", 2, "1-2-3"},
+		{"0 5-1 * * *", "minute", 2, "5-1"},
+		{"0 -1 * * *", "minute", 2, "-1"},
+		{"0 0 0 32W * ?", "day of month", 6, "32W"},
+		{"0 0 0 xW * ?", "day of month", 6, "xW"},
+		{"0 0 0 ? * 7L", "day of week", 10, "7L"},
+		{"0 0 0 ? * 1#6", "day of week", 10, "1#6"},
+		{"0 0 0 ? * Foo#1", "day of week", 10, "Foo#1"},
+		{"0 0 0 * * ? 2100", "year", 12, "2100"},
+		{"0 0 0 * * ? 1969-2000", "year", 12, "1969-2000"},
+		{"0 0 0 * * ? * *", "", 0, "0 0 0 * * ? * *"},
+		{"0 H * * *", "minute", 2, "H"},
+		{"@bogus", "descriptor", 0, "@bogus"},
+		{"  @every 1x", "descriptor", 2, "@every 1x"},
+		{"CRON_TZ=Invalid/Zone 0 0 * * *", "time zone", 8, "Invalid/Zone"},
+		{"TZ=UTC", "time zone", 3, "UTC"},
+		{"CRON_TZ= 0 0 9 * * *", "time zone", 8, ""},
+		{"CRON_TZ=", "time zone", 8, ""},
+		{"TZ=Local 0 0 9 * * *", "time zone", 3, "Local"},
+		{"TZ=Local", "time zone", 3, "Local"},
+		{"TZ=UTC  0 0 * * 13", "month", 16, "13"},
+	}
+
+	for _, c := range entries {
+		_, err := Parse(c.expr)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("%q => expected a *ParseError, got %v", c.expr, err)
+			continue
+		}
+		if parseErr.Spec != c.expr || parseErr.Field != c.field || parseErr.Position != c.position || parseErr.Token != c.token {
+			t.Errorf("%q => (expected) %q %d %q != %q %d %q (actual)",
+				c.expr, c.field, c.position, c.token, parseErr.Field, parseErr.Position, parseErr.Token)
+		}
+	}
+}
+
+func TestHashedField(t *testing.T) {
+	entries := []struct {
+		expr     string
+		field    specField
+		min, max uint
+		count    int
+	}{
+		{"H", specFields[1], 0, 59, 1},
+		{"H(0-29)", specFields[1], 0, 29, 1},
+		{"H(10-12)", specFields[2], 10, 12, 1},
+		{"H/15", specFields[0], 0, 59, 4},
+		{"H(0-29)/10", specFields[1], 0, 29, 3},
+		{"H/6", specFields[2], 0, 23, 4},
+		{"H", specFields[3], 1, 28, 1},
+		{"H", specFields[4], 1, 12, 1},
+		{"H", specFields[5], 0, 6, 1},
+	}
+
+	for _, c := range entries {
+		values := map[uint64]bool{}
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprintf("job_%d", i)
+			actual, err := parseField(token{c.expr, 0}, c.field, &SpecSchedule{}, key)
+			if err != nil {
+				t.Fatalf("%s => unexpected error %v", c.expr, err)
+			}
+			again, _ := parseField(token{c.expr, 0}, c.field, &SpecSchedule{}, key)
+			if again != actual {
+				t.Errorf("%s, %s => (expected) %b != %b (actual)", c.expr, key, actual, again)
+			}
+			if actual&^getBits(c.min, c.max, 1) != 0 || bits.OnesCount64(actual) != c.count {
+				t.Errorf("%s, %s => unexpected bits %b", c.expr, key, actual)
+			}
+			values[actual] = true
+		}
+		if len(values) < 2 {
+			t.Errorf("%s => expected the jobs to be spread out", c.expr)
+		}
+	}
+
+	// The fields of a job are hashed independently
+	schedule, err := defaultParser.WithHashKey("job").Parse("H H * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := schedule.(*SpecSchedule); s.Second == s.Minute {
+		t.Errorf("expected different seconds and minutes, got %b", s.Second)
+	}
+
+	invalid := []string{"H", "H(0-60)", "H(5)", "H(1-10/2)", "H(0-29", "H/0", "H/x", "Hx"}
+	for i, expr := range invalid {
+		key := "job"
+		if i == 0 {
+			key = ""
+		}
+		_, err := parseField(token{expr, 0}, specFields[1], &SpecSchedule{}, key)
+		if err == nil {
+			t.Errorf("%s => expected an error", expr)
+		}
+	}
+}
+
+func TestParser(t *testing.T) {
+	standard := Minute | Hour | Dom | Month | Dow | Descriptor
+	entries := []struct {
+		options  ParseOption
+		expr     string
+		expected Schedule
+		err      bool
+	}{
+		// Standard crontab: minute first
+		{standard, "0 9 * * *", &SpecSchedule{Second: 1 << 0, Minute: 1 << 0, Hour: 1 << 9, Dom: all(dom), Month: all(months), Dow: all(dow)}, false},
+		{standard, "0 9 * * 1-5", &SpecSchedule{Second: 1 << 0, Minute: 1 << 0, Hour: 1 << 9, Dom: all(dom), Month: all(months), Dow: 0x3e}, false},
+		{standard, "0 0 9 * * *", nil, true},
+		{standard, "@hourly", &SpecSchedule{Second: 1 << 0, Minute: 1 << 0, Hour: all(hours), Dom: all(dom), Month: all(months), Dow: all(dow)}, false},
+
+		// With seconds
+		{Second | standard, "0 9 * * *", nil, true},
+		{Second | standard, "30 0 9 * * *", &SpecSchedule{Second: 1 << 30, Minute: 1 << 0, Hour: 1 << 9, Dom: all(dom), Month: all(months), Dow: all(dow)}, false},
+
+		// Optional seconds
+		{SecondOptional | standard, "0 9 * * *", &SpecSchedule{Second: 1 << 0, Minute: 1 << 0, Hour: 1 << 9, Dom: all(dom), Month: all(months), Dow: all(dow)}, false},
+		{SecondOptional | standard, "30 0 9 * * *", &SpecSchedule{Second: 1 << 30, Minute: 1 << 0, Hour: 1 << 9, Dom: all(dom), Month: all(months), Dow: all(dow)}, false},
+		{SecondOptional | standard, "9 * * *", nil, true},
+
+		// Optional day of week
+		{Minute | Hour | Dom | Month | DowOptional, "0 9 * *", &SpecSchedule{Second: 1 << 0, Minute: 1 << 0, Hour: 1 << 9, Dom: all(dom), Month: all(months), Dow: all(dow)}, false},
+		{Minute | Hour | Dom | Month | DowOptional, "0 9 * * 0", &SpecSchedule{Second: 1 << 0, Minute: 1 << 0, Hour: 1 << 9, Dom: all(dom), Month: all(months), Dow: 1 << 0}, false},
+
+		// Fields which are not part of the dialect take their default value
+		{Hour | Dom, "9 15", &SpecSchedule{Second: 1 << 0, Minute: 1 << 0, Hour: 1 << 9, Dom: 1 << 15, Month: all(months), Dow: all(dow)}, false},
+
+		// Year
+		{standard | Year, "0 9 * * * 2027", &SpecSchedule{Second: 1 << 0, Minute: 1 << 0, Hour: 1 << 9, Dom: all(dom), Month: all(months), Dow: all(dow), Year: []uint64{1 << 57, 0, 0}}, false},
+		{standard | Year, "0 9 * * *", nil, true},
+		{standard | YearOptional, "0 9 * * *", &SpecSchedule{Second: 1 << 0, Minute: 1 << 0, Hour: 1 << 9, Dom: all(dom), Month: all(months), Dow: all(dow)}, false},
+
+		// Optional day of week and year, the year is omitted first
+		{Minute | Hour | Dom | Month | DowOptional | YearOptional, "0 9 * *", &SpecSchedule{Second: 1 << 0, Minute: 1 << 0, Hour: 1 << 9, Dom: all(dom), Month: all(months), Dow: all(dow)}, false},
+		{Minute | Hour | Dom | Month | DowOptional | YearOptional, "0 9 * * 0", &SpecSchedule{Second: 1 << 0, Minute: 1 << 0, Hour: 1 << 9, Dom: all(dom), Month: all(months), Dow: 1 << 0}, false},
+		{Minute | Hour | Dom | Month | DowOptional | YearOptional, "0 9 * * 0 2027", &SpecSchedule{Second: 1 << 0, Minute: 1 << 0, Hour: 1 << 9, Dom: all(dom), Month: all(months), Dow: 1 << 0, Year: []uint64{1 << 57, 0, 0}}, false},
+
+		// Descriptors
+		{Minute | Hour | Dom | Month | Dow, "@hourly", nil, true},
+		{Descriptor, "@every 5m", ConstantDelaySchedule{5 * time.Minute}, false},
+		{Descriptor, "* * * * *", nil, true},
+	}
+
+	for _, c := range entries {
+		parser, err := NewParser(c.options)
+		if err != nil {
+			t.Errorf("%s => unexpected error %v", c.expr, err)
+			continue
+		}
+		actual, err := parser.Parse(c.expr)
+		if c.err {
+			if err == nil {
+				t.Errorf("%s => expected an error", c.expr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s => unexpected error %v", c.expr, err)
+		}
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("%s => (expected) %b != %b (actual)", c.expr, c.expected, actual)
+		}
+	}
+}
+
+func TestNewParserInvalidOptions(t *testing.T) {
+	invalidOptions := []ParseOption{
+		0,
+		SecondOptional | Minute | Hour | Dom | Month | DowOptional,
+		SecondOptional | Minute | Hour | Dom | Month | Dow | YearOptional,
+	}
+	for _, options := range invalidOptions {
+		_, err := NewParser(options)
+		if err == nil {
+			t.Errorf("%b => expected an error", options)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package etcdcron
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	etcdclient "go.etcd.io/etcd/client/v3"
+)
+
+// ErrJobPaused is given to the skipped iterations handler when an iteration is
+// not run because its job has been paused with Pause.
+var ErrJobPaused = errors.New("job paused")
+
+// pausedJobs caches the paused state of the jobs, by canonical name, as last
+// seen by this process. It is kept up to date by watching etcd.
+type pausedJobs struct {
+	mutex  sync.Mutex
+	paused map[string]bool
+}
+
+func (p *pausedJobs) set(job Job, paused bool) {
+	p.setName(job.canonicalName(), paused)
+}
+
+func (p *pausedJobs) setName(name string, paused bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.paused == nil {
+		p.paused = map[string]bool{}
+	}
+	if paused {
+		p.paused[name] = true
+	} else {
+		delete(p.paused, name)
+	}
+}
+
+// reset replaces the paused state of all the jobs.
+func (p *pausedJobs) reset(paused map[string]bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.paused = paused
+}
+
+func (p *pausedJobs) get(job Job) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.paused[job.canonicalName()]
+}
+
+// pausedPrefix returns the prefix of the paused flags. As the trigger
+// requests, they are stored apart from the keys of the jobs, to be listed and
+// watched on their own.
+func (c *Cron) pausedPrefix() string {
+	return c.keyPrefix + "/.paused/"
+}
+
+func (c *Cron) pausedKey(job Job) string {
+	return c.pausedPrefix() + job.canonicalName()
+}
+
+// Pause pauses the job with the given name on all the nodes: its iterations
+// are skipped until Resume is called. The job does not need to be registered
+// on this node.
+func (c *Cron) Pause(ctx context.Context, name string) error {
+	client, ok := c.etcdClient()
+	if !ok {
+		return ErrNoEtcdClient
+	}
+	job := Job{Name: name}
+	_, err := client.Put(ctx, c.pausedKey(job), "true")
+	if err != nil {
+		return errors.Wrapf(err, "fail to pause job '%v'", name)
+	}
+	c.paused.set(job, true)
+	return nil
+}
+
+// Resume resumes the job with the given name, paused with Pause, on all the
+// nodes.
+func (c *Cron) Resume(ctx context.Context, name string) error {
+	client, ok := c.etcdClient()
+	if !ok {
+		return ErrNoEtcdClient
+	}
+	job := Job{Name: name}
+	_, err := client.Delete(ctx, c.pausedKey(job))
+	if err != nil {
+		return errors.Wrapf(err, "fail to resume job '%v'", name)
+	}
+	c.paused.set(job, false)
+	return nil
+}
+
+// isPaused returns true if the job is paused, according to etcd. The paused
+// state cache is updated.
+func (c *Cron) isPaused(ctx context.Context, job Job) (bool, error) {
+	client, ok := c.etcdClient()
+	if !ok {
+		return false, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.lockTimeout)
+	defer cancel()
+
+	resp, err := client.Get(ctx, c.pausedKey(job), etcdclient.WithCountOnly())
+	if err != nil {
+		return false, errors.Wrapf(err, "fail to get paused state of job '%v'", job.Name)
+	}
+	paused := resp.Count > 0
+	c.paused.set(job, paused)
+	return paused, nil
+}
+
+// watchPausedJobs loads the paused state of all the jobs, then watches its
+// changes until ctx is done, so that Entries reports the jobs paused or resumed
+// on the other nodes, including the jobs added after Start.
+func (c *Cron) watchPausedJobs(ctx context.Context) {
+	c.keepWatching(ctx, "paused jobs", c.syncPausedJobs)
+}
+
+// syncPausedJobs loads the paused flags (<prefix>/.paused/<job>), then watches
+// their changes until an error occurs.
+func (c *Cron) syncPausedJobs(ctx context.Context, client *etcdclient.Client) error {
+	prefix := c.pausedPrefix()
+	resp, err := client.Get(ctx, prefix, etcdclient.WithPrefix(), etcdclient.WithKeysOnly())
+	if err != nil {
+		return errors.Wrap(err, "fail to get paused jobs")
+	}
+	paused := map[string]bool{}
+	for _, kv := range resp.Kvs {
+		paused[strings.TrimPrefix(string(kv.Key), prefix)] = true
+	}
+	c.paused.reset(paused)
+
+	watch := client.Watch(etcdclient.WithRequireLeader(ctx), prefix,
+		etcdclient.WithPrefix(), etcdclient.WithRev(resp.Header.Revision+1))
+	for resp := range watch {
+		if err := resp.Err(); err != nil {
+			return err
+		}
+		for _, ev := range resp.Events {
+			c.paused.setName(strings.TrimPrefix(string(ev.Kv.Key), prefix), ev.Type == etcdclient.EventTypePut)
+		}
+	}
+	if ctx.Err() == nil {
+		return errors.New("watch closed")
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package etcdcron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	etcdclient "go.etcd.io/etcd/client/v3"
+)
+
+func TestPauseWithoutEtcdClient(t *testing.T) {
+	cron, err := New(WithEtcdMutexBuilder(newMemoryMutexBuilder()))
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	err = cron.Pause(context.Background(), "job")
+	if !errors.Is(err, ErrNoEtcdClient) {
+		t.Fatalf("expected ErrNoEtcdClient, got %v", err)
+	}
+	err = cron.Resume(context.Background(), "job")
+	if !errors.Is(err, ErrNoEtcdClient) {
+		t.Fatalf("expected ErrNoEtcdClient, got %v", err)
+	}
+}
+
+func TestEntriesPaused(t *testing.T) {
+	cron, err := New(WithEtcdMutexBuilder(newMemoryMutexBuilder()))
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	cron.AddJob(Job{Name: "Paused Job", Rhythm: "@every 1s", Func: func(context.Context) error { return nil }})
+	cron.AddJob(Job{Name: "running-job", Rhythm: "@every 1s", Func: func(context.Context) error { return nil }})
+	// The paused state is shared by the jobs with the same canonical name
+	cron.paused.set(Job{Name: "paused_job"}, true)
+
+	for _, entry := range cron.Entries() {
+		expected := entry.Job.Name == "Paused Job"
+		if entry.Paused != expected {
+			t.Errorf("%v: (expected) %v != %v (actual)", entry.Job.Name, expected, entry.Paused)
+		}
+	}
+}
+
+// Test that the paused flags are stored apart from the keys of the jobs, by
+// canonical name.
+func TestPausedKey(t *testing.T) {
+	cron, err := New(WithEtcdMutexBuilder(newMemoryMutexBuilder()), WithKeyPrefix("prefix"))
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	key := cron.pausedKey(Job{Name: "Paused Job"})
+	if key != "prefix/.paused/paused_job" {
+		t.Errorf("(expected) prefix/.paused/paused_job != %v (actual)", key)
+	}
+	if _, ok := parseLockKey(strings.TrimPrefix(key, "prefix/")); ok {
+		t.Errorf("expected %v not to be a lock key", key)
+	}
+}
+
+// Test that the iterations of a paused job are skipped on all the nodes until
+// it is resumed.
+func TestPause(t *testing.T) {
+	prefix := fmt.Sprintf("etcd_cron_test_pause_%d", time.Now().UnixNano())
+	runs := make(chan struct{}, 10)
+	skipped := make(chan error, 10)
+	cron, err := New(
+		WithKeyPrefix(prefix),
+		WithSkippedIterationsHandler(func(_ context.Context, _ Job, err error) {
+			skipped <- err
+		}),
+	)
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	defer cron.Close()
+	client, _ := cron.etcdClient()
+	defer client.Delete(context.Background(), prefix+"/", etcdclient.WithPrefix())
+
+	ctx, cancel := context.WithTimeout(context.Background(), ONE_SECOND)
+	defer cancel()
+	err = cron.Pause(ctx, "test-pause")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cron.AddJob(Job{
+		Name:   "test-pause",
+		Rhythm: "* * * * * ?",
+		Func: func(context.Context) error {
+			runs <- struct{}{}
+			return nil
+		},
+	})
+	cron.Start(context.Background())
+	defer cron.Stop()
+
+	select {
+	case <-time.After(2 * ONE_SECOND):
+		t.Fatal("expected iteration to be skipped")
+	case <-runs:
+		t.Fatal("expected paused job not to run")
+	case err := <-skipped:
+		if !errors.Is(err, ErrJobPaused) {
+			t.Fatalf("expected ErrJobPaused, got %v", err)
+		}
+	}
+	if entries := cron.Entries(); len(entries) != 1 || !entries[0].Paused {
+		t.Errorf("expected entry to be paused")
+	}
+
+	err = cron.Resume(ctx, "test-pause")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	select {
+	case <-time.After(2 * ONE_SECOND):
+		t.Fatal("expected resumed job to run")
+	case <-runs:
+	}
+	if entries := cron.Entries(); len(entries) != 1 || entries[0].Paused {
+		t.Errorf("expected entry not to be paused")
+	}
+}
+
+// Test that a job paused on another node is reported by Entries without waiting
+// for its next iteration, including a job added after Start.
+func TestEntriesPausedOnAnotherNode(t *testing.T) {
+	prefix := fmt.Sprintf("etcd_cron_test_pause_watch_%d", time.Now().UnixNano())
+	cron, err := New(WithKeyPrefix(prefix))
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	defer cron.Close()
+	other, err := New(WithKeyPrefix(prefix))
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	defer other.Close()
+	client, _ := cron.etcdClient()
+	defer client.Delete(context.Background(), prefix+"/", etcdclient.WithPrefix())
+
+	cron.AddJob(Job{Name: "daily", Rhythm: "@daily", Func: func(context.Context) error { return nil }})
+	cron.Start(context.Background())
+	defer cron.Stop()
+	cron.AddJob(Job{Name: "weekly", Rhythm: "@weekly", Func: func(context.Context) error { return nil }})
+
+	waitPaused := func(expected bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * ONE_SECOND)
+		for {
+			entries := cron.Entries()
+			if len(entries) == 2 && entries[0].Paused == expected && entries[1].Paused == expected {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected entries paused state to be %v, got %+v", expected, entries)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ONE_SECOND)
+	defer cancel()
+	for _, name := range []string{"daily", "weekly"} {
+		err = other.Pause(ctx, name)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	waitPaused(true)
+
+	for _, name := range []string{"daily", "weekly"} {
+		err = other.Resume(ctx, name)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	waitPaused(false)
+}
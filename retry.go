@@ -0,0 +1,117 @@
+package etcdcron
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	defaultRetryInitialBackoff = time.Second
+	defaultRetryMultiplier     = 2
+)
+
+// RetryPolicy defines how the failed executions of a job are retried. The
+// retries happen while holding the lock of the iteration, so that another node
+// can't run the same iteration.
+type RetryPolicy struct {
+	// Maximum number of attempts, including the first one
+	MaxAttempts int
+	// Delay before the first retry (optional, 1s by default)
+	InitialBackoff time.Duration
+	// Maximum delay between two attempts (optional, no maximum by default)
+	MaxBackoff time.Duration
+	// Factor applied to the delay after each retry (optional, 2 by default)
+	Multiplier float64
+	// Randomization factor of the delay, between 0 and 1: a 0.1 jitter gives a
+	// delay in [0.9*backoff, 1.1*backoff] (optional)
+	Jitter float64
+	// Returns true if the execution failing with the given error should be
+	// retried (optional, all the errors are retried by default)
+	Retryable func(error) bool
+}
+
+// backoff returns the delay to wait before the given attempt, 2 being the
+// first retry.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	initial := p.InitialBackoff
+	if initial <= 0 {
+		initial = defaultRetryInitialBackoff
+	}
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = defaultRetryMultiplier
+	}
+
+	backoff := float64(initial) * math.Pow(multiplier, float64(attempt-2))
+	if p.Jitter > 0 {
+		backoff *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+
+	// The cap is applied after the jitter, and the delay is clamped before its
+	// conversion as it overflows (or is NaN) after many attempts.
+	maxBackoff := time.Duration(math.MaxInt64)
+	if p.MaxBackoff > 0 {
+		maxBackoff = p.MaxBackoff
+	}
+	if !(backoff < float64(maxBackoff)) {
+		return maxBackoff
+	}
+	return time.Duration(backoff)
+}
+
+func (p RetryPolicy) isRetryable(err error) bool {
+	return p.Retryable == nil || p.Retryable(err)
+}
+
+// AttemptError is given to the errors handler for each failed attempt of a job
+// having a RetryPolicy.
+type AttemptError struct {
+	// Attempt number, starting at 1
+	Attempt     int
+	MaxAttempts int
+	Err         error
+}
+
+func (e *AttemptError) Error() string {
+	return fmt.Sprintf("attempt %d/%d: %v", e.Attempt, e.MaxAttempts, e.Err)
+}
+
+func (e *AttemptError) Unwrap() error {
+	return e.Err
+}
+
+// runWithRetries runs the job, and retries it according to its RetryPolicy.
+// The errors are given to the errors handler, the error of the last attempt is
+// returned.
+func (c *Cron) runWithRetries(ctx context.Context, job Job, next time.Time) error {
+	if job.Retry == nil || job.Retry.MaxAttempts <= 1 {
+		err := c.runWithTimeout(ctx, job, next)
+		if err != nil {
+			go c.errorsHandler(ctx, job, err)
+		}
+		return err
+	}
+
+	policy := *job.Retry
+	for attempt := 1; ; attempt++ {
+		err := c.runWithTimeout(ctx, job, next)
+		if err == nil {
+			return nil
+		}
+		go c.errorsHandler(ctx, job, &AttemptError{Attempt: attempt, MaxAttempts: policy.MaxAttempts, Err: err})
+		if attempt >= policy.MaxAttempts || !policy.isRetryable(err) {
+			return err
+		}
+
+		timer := c.clock.NewTimer(policy.backoff(attempt + 1))
+		select {
+		case <-timer.C():
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
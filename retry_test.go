@@ -0,0 +1,153 @@
+package etcdcron
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	tests := []struct {
+		policy   RetryPolicy
+		attempt  int
+		expected time.Duration
+	}{
+		{RetryPolicy{}, 2, time.Second},
+		{RetryPolicy{}, 3, 2 * time.Second},
+		{RetryPolicy{}, 5, 8 * time.Second},
+		{RetryPolicy{InitialBackoff: 100 * time.Millisecond, Multiplier: 3}, 4, 900 * time.Millisecond},
+		{RetryPolicy{MaxBackoff: 5 * time.Second}, 5, 5 * time.Second},
+		{RetryPolicy{}, 2000, time.Duration(math.MaxInt64)},
+		{RetryPolicy{Multiplier: 1e300}, 3, time.Duration(math.MaxInt64)},
+		{RetryPolicy{MaxBackoff: time.Minute}, 2000, time.Minute},
+	}
+
+	for _, test := range tests {
+		actual := test.policy.backoff(test.attempt)
+		if actual != test.expected {
+			t.Errorf("attempt %d of %+v => (expected) %v != %v (actual)", test.attempt, test.policy, test.expected, actual)
+		}
+	}
+}
+
+func TestRetryPolicyBackoffJitter(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 10 * time.Second, Jitter: 0.1}
+	for i := 0; i < 100; i++ {
+		backoff := policy.backoff(2)
+		if backoff < 9*time.Second || backoff > 11*time.Second {
+			t.Fatalf("backoff %v out of the jitter range", backoff)
+		}
+	}
+}
+
+func TestRetryPolicyBackoffJitterCapped(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 10 * time.Second, MaxBackoff: 10 * time.Second, Jitter: 1}
+	for _, attempt := range []int{2, 10, 2000} {
+		for i := 0; i < 100; i++ {
+			backoff := policy.backoff(attempt)
+			if backoff < 0 || backoff > policy.MaxBackoff {
+				t.Fatalf("attempt %d => backoff %v out of [0, %v]", attempt, backoff, policy.MaxBackoff)
+			}
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	errFailure := errors.New("failure")
+	errFatal := errors.New("fatal")
+
+	tests := map[string]struct {
+		failures         []error
+		expectedAttempts int
+	}{
+		"succeeds after retries": {
+			failures:         []error{errFailure, errFailure},
+			expectedAttempts: 3,
+		},
+		"fails after the maximum number of attempts": {
+			failures:         []error{errFailure, errFailure, errFailure, errFailure},
+			expectedAttempts: 3,
+		},
+		"stops on a non retryable error": {
+			failures:         []error{errFailure, errFatal, errFailure},
+			expectedAttempts: 2,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			errs := make(chan error, 10)
+			attempts := make(chan int, 10)
+			clock := NewFakeClock(time.Date(2012, time.July, 9, 14, 45, 0, 0, time.Local))
+			cron, err := New(
+				WithClock(clock),
+				WithEtcdMutexBuilder(newMemoryMutexBuilder()),
+				WithErrorsHandler(func(_ context.Context, _ Job, err error) { errs <- err }),
+			)
+			if err != nil {
+				t.Fatal("unexpected error")
+			}
+
+			attempt := 0
+			cron.AddJob(Job{
+				Name:   "test-retry",
+				Rhythm: "@every 1m",
+				Retry: &RetryPolicy{
+					MaxAttempts: 3,
+					Retryable:   func(err error) bool { return !errors.Is(err, errFatal) },
+				},
+				Func: func(context.Context) error {
+					attempt++
+					attempts <- attempt
+					if attempt <= len(test.failures) {
+						return test.failures[attempt-1]
+					}
+					return nil
+				},
+			})
+			cron.Start(context.Background())
+			defer cron.Stop()
+
+			clock.BlockUntil(1)
+			clock.Advance(time.Minute)
+
+			for i := 1; i <= test.expectedAttempts; i++ {
+				select {
+				case <-time.After(ONE_SECOND):
+					t.Fatalf("expected attempt %d", i)
+				case <-attempts:
+				}
+				if i > len(test.failures) {
+					break
+				}
+
+				select {
+				case <-time.After(ONE_SECOND):
+					t.Fatalf("expected attempt %d error", i)
+				case err := <-errs:
+					var attemptErr *AttemptError
+					if !errors.As(err, &attemptErr) || attemptErr.Attempt != i || attemptErr.MaxAttempts != 3 {
+						t.Fatalf("unexpected error %v", err)
+					}
+					if !errors.Is(err, test.failures[i-1]) {
+						t.Errorf("expected the error of the job to be wrapped, got %v", err)
+					}
+				}
+
+				if i < test.expectedAttempts {
+					// Wait for the backoff timer next to the scheduler one.
+					clock.BlockUntil(2)
+					clock.Advance(RetryPolicy{}.backoff(i + 1))
+				}
+			}
+
+			select {
+			case <-time.After(100 * time.Millisecond):
+			case <-attempts:
+				t.Fatal("unexpected attempt")
+			}
+		})
+	}
+}
@@ -8,6 +8,25 @@ import (
 // traditional crontab specification. It is computed initially and stored as bit sets.
 type SpecSchedule struct {
 	Second, Minute, Hour, Dom, Month, Dow uint64
+
+	// Location in which the schedule is evaluated, the location of the time
+	// given to Next if nil.
+	Location *time.Location
+
+	// Quartz-style modifiers of the day of month field: the last day (L) and
+	// the last weekday (LW) of the month, and the weekdays nearest to the days
+	// of month set in NearestWeekday (nW).
+	LastDay, LastWeekday bool
+	NearestWeekday       uint64
+
+	// Quartz-style modifiers of the day of week field: the last occurrence in
+	// the month of the days of week set in LastDow (nL), and the k-th
+	// occurrence of day of week n if bit 7*(k-1)+n of NthDow is set (n#k).
+	LastDow, NthDow uint64
+
+	// Years of the schedule, as a bit set where bit i stands for the year
+	// years.min+i, every year if nil.
+	Year []uint64
 }
 
 // bounds provides a range of acceptable values (plus a map of name to value).
@@ -45,6 +64,7 @@ var (
 		"fri": 5,
 		"sat": 6,
 	}}
+	years = bounds{1970, 2099, nil}
 )
 
 const (
@@ -53,8 +73,47 @@ const (
 )
 
 // Next returns the next time this schedule is activated, greater than the given
-// time.  If no time can be found to satisfy the schedule, return the zero time.
+// time.  If no time can be found to satisfy the schedule, return the zero time,
+// ie. once the years of the schedule are over.
+//
+// The schedule is matched against the wall clock of its location (or of the
+// location of the given time), with the following semantics on daylight saving
+// time transitions:
+//   - A wall clock time skipped when the clocks go forward is activated at the
+//     first instant after the gap (ie. 02:30 is activated at 03:00), the
+//     activations of the gap being merged into a single one.
+//   - A wall clock time repeated when the clocks go back is activated once, at
+//     its first occurrence.
+//
+// The returned time is in the location of the given time.
 func (s *SpecSchedule) Next(t time.Time) time.Time {
+	origLocation := t.Location()
+	location := origLocation
+	if s.Location != nil {
+		location = s.Location
+	}
+	t = t.In(location)
+
+	// The search is done on the wall clock, represented in UTC where there is no
+	// transition.
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	for {
+		wall = s.nextWall(wall)
+		if wall.IsZero() {
+			return time.Time{}
+		}
+		next := wallInstant(wall, location)
+		// Otherwise the wall clock time is repeated, and its first occurrence
+		// has already been activated.
+		if next.After(t) {
+			return next.In(origLocation)
+		}
+	}
+}
+
+// nextWall returns the next wall clock time matching the schedule, greater than
+// the given wall clock time, both in UTC.
+func (s *SpecSchedule) nextWall(t time.Time) time.Time {
 	// General approach:
 	// For Month, Day, Hour, Minute, Second:
 	// Check if the time value matches.  If yes, continue to the next field.
@@ -69,14 +128,27 @@ func (s *SpecSchedule) Next(t time.Time) time.Time {
 	// This flag indicates whether a field has been incremented.
 	added := false
 
-	// If no time is found within five years, return zero.
+	// If no time is found within five years, or after the last year of the
+	// schedule, return zero.
 	yearLimit := t.Year() + 5
+	if s.Year != nil {
+		yearLimit = s.lastYear()
+	}
 
 WRAP:
 	if t.Year() > yearLimit {
 		return time.Time{}
 	}
 
+	// Find the first applicable year.
+	for !s.yearMatches(t.Year()) {
+		if t.Year() >= yearLimit {
+			return time.Time{}
+		}
+		added = true
+		t = time.Date(t.Year()+1, time.January, 1, 0, 0, 0, 0, t.Location())
+	}
+
 	// Find the first applicable month.
 	// If it's this month, then do nothing.
 	for 1<<uint(t.Month())&s.Month == 0 {
@@ -146,12 +218,81 @@ WRAP:
 	return t
 }
 
+// wallInstant returns the first instant at which the wall clock of the location
+// shows the given wall clock time, or the first instant after the gap if the
+// wall clock time is skipped by a transition.
+func wallInstant(wall time.Time, location *time.Location) time.Time {
+	t := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, location)
+	start, end := t.ZoneBounds()
+
+	// The same wall clock time may be shown earlier, before the clocks went
+	// back.
+	if !start.IsZero() {
+		_, previousOffset := start.Add(-time.Second).Zone()
+		earlier := time.Unix(wall.Unix()-int64(previousOffset), 0).In(location)
+		if earlier.Before(start) && sameWall(earlier, wall) {
+			return earlier
+		}
+	}
+	if sameWall(t, wall) {
+		return t
+	}
+
+	// The wall clock time is skipped: t is normalized either after the gap, in
+	// the zone starting with it, or before it, in the zone ending with it.
+	if wallOf(t).After(wall) {
+		return start
+	}
+	return end
+}
+
+// wallOf returns the wall clock time of t, in UTC.
+func wallOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+}
+
+func sameWall(t, wall time.Time) bool {
+	return wallOf(t).Equal(wall)
+}
+
+// yearMatches returns true if the year is one of the years of the schedule.
+func (s *SpecSchedule) yearMatches(year int) bool {
+	if s.Year == nil {
+		return true
+	}
+	if year < int(years.min) || year > int(years.max) {
+		return false
+	}
+	i := uint(year) - years.min
+	return int(i/64) < len(s.Year) && s.Year[i/64]&(1<<(i%64)) > 0
+}
+
+// lastYear returns the last year of the schedule, which must have years.
+func (s *SpecSchedule) lastYear() int {
+	for year := int(years.max); year > int(years.min); year-- {
+		if s.yearMatches(year) {
+			return year
+		}
+	}
+	return int(years.min)
+}
+
+// firstYear returns the first year of the schedule, which must have years.
+func (s *SpecSchedule) firstYear() int {
+	for year := int(years.min); year < int(years.max); year++ {
+		if s.yearMatches(year) {
+			return year
+		}
+	}
+	return int(years.max)
+}
+
 // dayMatches returns true if the schedule's day-of-week and day-of-month
 // restrictions are satisfied by the given time.
 func dayMatches(s *SpecSchedule, t time.Time) bool {
 	var (
-		domMatch bool = 1<<uint(t.Day())&s.Dom > 0
-		dowMatch bool = 1<<uint(t.Weekday())&s.Dow > 0
+		domMatch bool = 1<<uint(t.Day())&s.Dom > 0 || domModifiersMatch(s, t)
+		dowMatch bool = 1<<uint(t.Weekday())&s.Dow > 0 || dowModifiersMatch(s, t)
 	)
 
 	if s.Dom&starBit > 0 || s.Dow&starBit > 0 {
@@ -159,3 +300,58 @@ func dayMatches(s *SpecSchedule, t time.Time) bool {
 	}
 	return domMatch || dowMatch
 }
+
+// domModifiersMatch returns true if the given time is matched by one of the
+// Quartz-style modifiers of the day of month field.
+func domModifiersMatch(s *SpecSchedule, t time.Time) bool {
+	lastDay := daysIn(t.Year(), t.Month())
+	switch {
+	case s.LastDay && t.Day() == lastDay:
+		return true
+	case s.LastWeekday && t.Day() == nearestWeekday(t.Year(), t.Month(), lastDay):
+		return true
+	}
+	if s.NearestWeekday == 0 {
+		return false
+	}
+	for day := 1; day <= lastDay; day++ {
+		if 1<<uint(day)&s.NearestWeekday > 0 && t.Day() == nearestWeekday(t.Year(), t.Month(), day) {
+			return true
+		}
+	}
+	return false
+}
+
+// dowModifiersMatch returns true if the given time is matched by one of the
+// Quartz-style modifiers of the day of week field.
+func dowModifiersMatch(s *SpecSchedule, t time.Time) bool {
+	weekday := uint(t.Weekday())
+	if 1<<weekday&s.LastDow > 0 && t.Day()+7 > daysIn(t.Year(), t.Month()) {
+		return true
+	}
+	nth := uint(t.Day()-1) / 7
+	return 1<<(7*nth+weekday)&s.NthDow > 0
+}
+
+// nearestWeekday returns the weekday (Monday to Friday) nearest to the given
+// day, without leaving its month.
+func nearestWeekday(year int, month time.Month, day int) int {
+	switch time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Weekday() {
+	case time.Saturday:
+		if day == 1 {
+			return day + 2
+		}
+		return day - 1
+	case time.Sunday:
+		if day == daysIn(year, month) {
+			return day - 2
+		}
+		return day + 1
+	}
+	return day
+}
+
+// daysIn returns the number of days of the month.
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
@@ -109,12 +109,48 @@ func TestNext(t *testing.T) {
 		// Leap year
 		{"Mon Jul 9 23:35 2012", "0 0 0 29 Feb ?", "Mon Feb 29 00:00 2016"},
 
-		// Daylight savings time EST -> EDT
-		{"2012-03-11T00:00:00-0500", "0 30 2 11 Mar ?", "2013-03-11T02:30:00-0400"},
+		// Daylight savings time EST -> EDT: 02:30 is skipped, run at 03:00
+		{"2012-03-11T00:00:00-0500", "0 30 2 11 Mar ?", "2012-03-11T03:00:00-0400"},
 
-		// Daylight savings time EDT -> EST
+		// Daylight savings time EDT -> EST: 01:30 is repeated, run once
 		{"2012-11-04T00:00:00-0400", "0 30 2 04 Nov ?", "2012-11-04T02:30:00-0500"},
-		{"2012-11-04T01:45:00-0400", "0 30 1 04 Nov ?", "2012-11-04T01:30:00-0500"},
+		{"2012-11-04T01:45:00-0400", "0 30 1 04 Nov ?", "2013-11-04T01:30:00-0500"},
+
+		// Last day of month
+		{"Mon Jul 9 23:35 2012", "0 0 0 L * ?", "Tue Jul 31 00:00 2012"},
+		{"Wed Feb 1 00:00 2012", "0 0 0 L * ?", "Wed Feb 29 00:00 2012"},
+		{"Mon Jul 9 23:35 2012", "0 0 0 L Feb ?", "Thu Feb 28 00:00 2013"},
+		{"Mon Jul 9 23:35 2012", "0 0 0 l,15 * ?", "Sun Jul 15 00:00 2012"},
+
+		// Weekday nearest to a day of month
+		{"Sat Sep 1 00:00 2012", "0 0 0 LW * ?", "Fri Sep 28 00:00 2012"},
+		{"Sat Sep 1 00:00 2012", "0 0 0 15W * ?", "Fri Sep 14 00:00 2012"},
+		{"Thu Aug 2 00:00 2012", "0 0 0 1W * ?", "Mon Sep 3 00:00 2012"},
+		{"Sat Sep 1 00:00 2012", "0 0 0 30W * ?", "Fri Sep 28 00:00 2012"},
+		{"Sat Sep 1 00:00 2012", "0 0 0 31W * ?", "Wed Oct 31 00:00 2012"},
+
+		// Last day of week of month
+		{"Mon Jul 9 23:35 2012", "0 0 0 ? * 5L", "Fri Jul 27 00:00 2012"},
+		{"Mon Jul 9 23:35 2012", "0 0 0 ? * FriL", "Fri Jul 27 00:00 2012"},
+		{"Mon Jul 9 23:35 2012", "0 0 0 ? Feb 3L", "Wed Feb 27 00:00 2013"},
+
+		// Nth day of week of month
+		{"Mon Jul 9 23:35 2012", "0 0 0 ? * 2#2", "Tue Jul 10 00:00 2012"},
+		{"Mon Jul 9 23:35 2012", "0 0 0 ? * Mon#5", "Mon Jul 30 00:00 2012"},
+		{"Mon Jul 9 23:35 2012", "0 0 0 ? * 3#5", "Wed Aug 29 00:00 2012"},
+		{"Mon Jul 9 23:35 2012", "0 0 0 ? * 1#1,5L", "Fri Jul 27 00:00 2012"},
+
+		// Years
+		{"Mon Jul 9 23:35 2012", "0 0 0 ? * Mon 2027", "Mon Jan 4 00:00 2027"},
+		{"Mon Dec 27 00:00 2027", "0 0 0 ? * Mon 2027", ""},
+		{"Mon Jul 9 23:35 2012", "0 0 0 1 Jan ? 2020-2030/5", "Wed Jan 1 00:00 2020"},
+		{"Wed Jan 1 00:00 2020", "0 0 0 1 Jan ? 2020-2030/5", "Wed Jan 1 00:00 2025"},
+		{"Tue Jan 1 00:00 2030", "0 0 0 1 Jan ? 2020-2030/5", ""},
+		{"Mon Jul 9 23:35 2012", "0 0 0 1 Jan ? 2099", "Thu Jan 1 00:00 2099"},
+		{"Mon Jul 9 23:35 2012", "0 0 0 29 Feb ? 2014-2017", "Mon Feb 29 00:00 2016"},
+		{"Mon Jul 9 23:35 2012", "0 0 0 29 Feb ? 2013-2015", ""},
+		{"Mon Jul 9 23:35 2012", "0 0 0 1 Jan ? 2000-2010", ""},
+		{"Mon Jul 9 23:35 2012", "0 0 0 * * ? *", "Tue Jul 10 00:00 2012"},
 
 		// Unsatisfiable
 		{"Mon Jul 9 23:35 2012", "0 0 0 30 Feb ?", ""},
@@ -135,12 +171,110 @@ func TestNext(t *testing.T) {
 	}
 }
 
+func TestNextLocation(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs := []struct {
+		time     time.Time
+		spec     string
+		expected time.Time
+	}{
+		// Evaluated in the location of the given time
+		{time.Date(2012, time.July, 9, 14, 45, 0, 0, time.UTC), "0 0 9 * * *", time.Date(2012, time.July, 10, 9, 0, 0, 0, time.UTC)},
+		{time.Date(2012, time.July, 9, 14, 45, 0, 0, tokyo), "0 0 9 * * *", time.Date(2012, time.July, 10, 9, 0, 0, 0, tokyo)},
+		// Evaluated in the location of the spec
+		{time.Date(2012, time.July, 9, 14, 45, 0, 0, time.UTC), "CRON_TZ=Asia/Tokyo 0 0 9 * * *", time.Date(2012, time.July, 10, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2012, time.July, 9, 14, 45, 0, 0, tokyo), "TZ=UTC 0 0 9 * * *", time.Date(2012, time.July, 9, 9, 0, 0, 0, time.UTC)},
+		{time.Date(2012, time.July, 9, 23, 45, 0, 0, time.UTC), "CRON_TZ=Asia/Tokyo @daily", time.Date(2012, time.July, 10, 15, 0, 0, 0, time.UTC)},
+	}
+
+	for _, c := range runs {
+		sched, err := Parse(c.spec)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		actual := sched.Next(c.time)
+		if !actual.Equal(c.expected) {
+			t.Errorf("%v, \"%s\": (expected) %v != %v (actual)", c.time, c.spec, c.expected, actual)
+		}
+		if actual.Location() != c.time.Location() {
+			t.Errorf("%v, \"%s\": expected result in location %v, got %v", c.time, c.spec, c.time.Location(), actual.Location())
+		}
+	}
+}
+
+func TestNextDST(t *testing.T) {
+	runs := []struct {
+		location   string
+		time, spec string
+		expected   string
+	}{
+		// Paris, CET -> CEST on 2024-03-31 at 02:00
+		{"Europe/Paris", "2024-03-31T00:00:00+01:00", "0 30 2 * * *", "2024-03-31T03:00:00+02:00"},
+		{"Europe/Paris", "2024-03-31T03:00:00+02:00", "0 30 2 * * *", "2024-04-01T02:30:00+02:00"},
+		{"Europe/Paris", "2024-03-31T01:50:00+01:00", "0 */15 * * * *", "2024-03-31T03:00:00+02:00"},
+		{"Europe/Paris", "2024-03-31T03:00:00+02:00", "0 */15 * * * *", "2024-03-31T03:15:00+02:00"},
+		{"Europe/Paris", "2024-03-31T01:59:59+01:00", "* * * * * *", "2024-03-31T03:00:00+02:00"},
+		{"Europe/Paris", "2024-03-31T00:00:00+01:00", "0 0 3 * * *", "2024-03-31T03:00:00+02:00"},
+
+		// Paris, CEST -> CET on 2024-10-27 at 03:00
+		{"Europe/Paris", "2024-10-27T00:00:00+02:00", "0 30 2 * * *", "2024-10-27T02:30:00+02:00"},
+		{"Europe/Paris", "2024-10-27T02:30:00+02:00", "0 30 2 * * *", "2024-10-28T02:30:00+01:00"},
+		{"Europe/Paris", "2024-10-27T02:30:00+02:00", "0 */30 * * * *", "2024-10-27T03:00:00+01:00"},
+		{"Europe/Paris", "2024-10-27T02:10:00+01:00", "0 */30 * * * *", "2024-10-27T03:00:00+01:00"},
+		{"Europe/Paris", "2024-10-27T01:30:00+02:00", "@hourly", "2024-10-27T02:00:00+02:00"},
+		{"Europe/Paris", "2024-10-27T02:00:00+02:00", "@hourly", "2024-10-27T03:00:00+01:00"},
+		{"Europe/Paris", "2024-10-27T02:59:59+02:00", "* * * * * *", "2024-10-27T03:00:00+01:00"},
+
+		// New York, EST -> EDT on 2024-03-10 at 02:00, EDT -> EST on 2024-11-03 at 02:00
+		{"America/New_York", "2024-03-10T00:00:00-05:00", "0 30 2 * * *", "2024-03-10T03:00:00-04:00"},
+		{"America/New_York", "2024-11-03T00:00:00-04:00", "0 30 1 * * *", "2024-11-03T01:30:00-04:00"},
+		{"America/New_York", "2024-11-03T01:30:00-04:00", "0 30 1 * * *", "2024-11-04T01:30:00-05:00"},
+		{"America/New_York", "2024-11-03T01:00:00-05:00", "0 45 1 * * *", "2024-11-04T01:45:00-05:00"},
+
+		// Sydney, AEDT -> AEST on 2024-04-07 at 03:00, AEST -> AEDT on 2024-10-06 at 02:00
+		{"Australia/Sydney", "2024-04-07T00:00:00+11:00", "0 30 2 * * *", "2024-04-07T02:30:00+11:00"},
+		{"Australia/Sydney", "2024-04-07T02:30:00+11:00", "0 30 2 * * *", "2024-04-08T02:30:00+10:00"},
+		{"Australia/Sydney", "2024-10-06T00:00:00+10:00", "0 30 2 * * *", "2024-10-06T03:00:00+11:00"},
+
+		// Lord Howe, 30 minutes shift: LHST -> LHDT on 2024-10-06 at 02:00
+		{"Australia/Lord_Howe", "2024-10-06T00:00:00+10:30", "0 15 2 * * *", "2024-10-06T02:30:00+11:00"},
+		{"Australia/Lord_Howe", "2024-10-06T00:00:00+10:30", "0 45 2 * * *", "2024-10-06T02:45:00+11:00"},
+
+		// No transition
+		{"Asia/Kolkata", "2024-03-31T00:00:00+05:30", "0 30 2 * * *", "2024-03-31T02:30:00+05:30"},
+	}
+
+	for _, c := range runs {
+		location, err := time.LoadLocation(c.location)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sched, err := Parse(c.spec)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		from, _ := time.Parse(time.RFC3339, c.time)
+		expected, _ := time.Parse(time.RFC3339, c.expected)
+		actual := sched.Next(from.In(location))
+		if !actual.Equal(expected) {
+			t.Errorf("%s %s, \"%s\": (expected) %v != %v (actual)", c.location, c.time, c.spec, expected, actual.In(location))
+		}
+	}
+}
+
 func TestErrors(t *testing.T) {
 	invalidSpecs := []string{
 		"xyz",
 		"60 0 * * *",
 		"0 60 * * *",
 		"0 0 * * XYZ",
+		"CRON_TZ=Invalid/Zone 0 0 * * *",
+		"TZ=UTC",
 	}
 	for _, spec := range invalidSpecs {
 		_, err := Parse(spec)
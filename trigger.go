@@ -0,0 +1,181 @@
+package etcdcron
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	etcdclient "go.etcd.io/etcd/client/v3"
+)
+
+// triggerRequestTTL is the duration after which a trigger request which has not
+// been handled by any node is dropped.
+const triggerRequestTTL = time.Hour
+
+// ErrIterationNotRun is returned by Trigger when the iteration could not be
+// run, ie. because of the overlap policy of the job. The reason is given to the
+// handlers.
+var ErrIterationNotRun = errors.New("iteration not run")
+
+// triggerRequest is stored in etcd by RequestTrigger, and handled by a single
+// node having the job.
+type triggerRequest struct {
+	ID          string    `json:"id"`
+	Job         string    `json:"job"`
+	RequestedAt time.Time `json:"requested_at"`
+
+	// Key and revision of the request, to claim it
+	key         string
+	modRevision int64
+}
+
+// triggersPrefix returns the prefix of the trigger requests. The dot can't be
+// part of a canonical job name, the requests can't collide with the keys of a
+// job.
+func (c *Cron) triggersPrefix() string {
+	return c.keyPrefix + "/.triggers/"
+}
+
+func newTriggerID(now time.Time) string {
+	return fmt.Sprintf("%d_%08x", now.UnixNano(), rand.Uint32())
+}
+
+// Trigger runs the job with the given name once, outside of its rhythm, and
+// returns its error. The iteration goes through the same lock, pause and
+// overlap checks as the scheduled ones, with a lock of its own, so it can't
+// prevent a scheduled iteration from running. It returns ErrJobNotFound if no
+// such job exists, and ErrShuttingDown once Shutdown has been called.
+func (c *Cron) Trigger(ctx context.Context, name string) error {
+	job, err := c.Job(name)
+	if err != nil {
+		return err
+	}
+	if !c.startExecution() {
+		return ErrShuttingDown
+	}
+	defer c.executions.Done()
+	return c.trigger(ctx, job, newTriggerID(c.clock.Now()))
+}
+
+func (c *Cron) trigger(ctx context.Context, job Job, id string) error {
+	return c.runIteration(ctx, job, iteration{
+		lockName:    "trigger_" + id,
+		scheduledAt: c.clock.Now(),
+		manual:      true,
+	})
+}
+
+// RequestTrigger requests a single run of the job with the given name, outside
+// of its rhythm, to the whole cluster. Exactly one of the nodes having the job
+// runs it, the errors are given to the errors handler of this node. The request
+// is dropped if no node handles it within an hour.
+func (c *Cron) RequestTrigger(ctx context.Context, name string) error {
+	client, ok := c.etcdClient()
+	if !ok {
+		return ErrNoEtcdClient
+	}
+
+	now := c.clock.Now()
+	request := triggerRequest{ID: newTriggerID(now), Job: name, RequestedAt: now}
+	value, err := json.Marshal(request)
+	if err != nil {
+		return errors.Wrap(err, "fail to encode trigger request")
+	}
+	lease, err := client.Grant(ctx, int64(triggerRequestTTL/time.Second))
+	if err != nil {
+		return errors.Wrap(err, "fail to grant lease of trigger request")
+	}
+	_, err = client.Put(ctx, c.triggersPrefix()+request.ID, string(value), etcdclient.WithLease(lease.ID))
+	if err != nil {
+		return errors.Wrapf(err, "fail to request trigger of job '%v'", name)
+	}
+	return nil
+}
+
+// watchTriggerRequests sends the pending trigger requests, then the new ones,
+// to the scheduler goroutine until ctx is done.
+func (c *Cron) watchTriggerRequests(ctx context.Context) {
+	c.keepWatching(ctx, "trigger requests", c.syncTriggerRequests)
+}
+
+func (c *Cron) syncTriggerRequests(ctx context.Context, client *etcdclient.Client) error {
+	prefix := c.triggersPrefix()
+	resp, err := client.Get(ctx, prefix, etcdclient.WithPrefix())
+	if err != nil {
+		return errors.Wrap(err, "fail to get trigger requests")
+	}
+	for _, kv := range resp.Kvs {
+		if !c.sendTriggerRequest(ctx, string(kv.Key), kv.Value, kv.ModRevision) {
+			return nil
+		}
+	}
+
+	watch := client.Watch(etcdclient.WithRequireLeader(ctx), prefix,
+		etcdclient.WithPrefix(), etcdclient.WithFilterDelete(), etcdclient.WithRev(resp.Header.Revision+1))
+	for resp := range watch {
+		if err := resp.Err(); err != nil {
+			return err
+		}
+		for _, ev := range resp.Events {
+			if !c.sendTriggerRequest(ctx, string(ev.Kv.Key), ev.Kv.Value, ev.Kv.ModRevision) {
+				return nil
+			}
+		}
+	}
+	if ctx.Err() == nil {
+		return errors.New("watch closed")
+	}
+	return nil
+}
+
+// sendTriggerRequest decodes the request and sends it to the scheduler
+// goroutine. It returns false if ctx is done.
+func (c *Cron) sendTriggerRequest(ctx context.Context, key string, value []byte, modRevision int64) bool {
+	var request triggerRequest
+	err := json.Unmarshal(value, &request)
+	if err != nil {
+		go c.etcdErrorsHandler(ctx, Job{}, errors.Wrapf(err, "invalid trigger request '%v'", strings.TrimPrefix(key, c.triggersPrefix())))
+		return true
+	}
+	request.key = key
+	request.modRevision = modRevision
+
+	select {
+	case c.triggers <- request:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// handleTriggerRequest claims the trigger request by deleting it, and runs the
+// job if no other node claimed it first.
+func (c *Cron) handleTriggerRequest(ctx context.Context, job Job, request triggerRequest) {
+	defer c.executions.Done()
+
+	client, ok := c.etcdClient()
+	if !ok {
+		return
+	}
+	claimCtx, cancel := context.WithTimeout(ctx, c.lockTimeout)
+	defer cancel()
+	resp, err := client.Txn(claimCtx).
+		If(etcdclient.Compare(etcdclient.ModRevision(request.key), "=", request.modRevision)).
+		Then(etcdclient.OpDelete(request.key)).
+		Commit()
+	if err != nil {
+		go c.etcdErrorsHandler(ctx, job, errors.Wrapf(err, "fail to claim trigger request of job '%v'", job.Name))
+		return
+	}
+	if !resp.Succeeded {
+		// Another node runs the job.
+		return
+	}
+
+	// The errors are given to the handlers.
+	_ = c.trigger(ctx, job, request.ID)
+}
@@ -0,0 +1,181 @@
+package etcdcron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	etcdclient "go.etcd.io/etcd/client/v3"
+)
+
+// Test that Trigger runs the job once with its own lock, released afterwards,
+// and returns the error of the job.
+func TestTrigger(t *testing.T) {
+	clock := NewFakeClock(time.Date(2012, time.July, 9, 14, 45, 0, 0, time.Local))
+	builder := newMemoryMutexBuilder()
+	cron, err := New(WithClock(clock), WithEtcdMutexBuilder(builder))
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	jobErr := errors.New("failure")
+	var scheduledAt time.Time
+	cron.AddJob(Job{
+		Name:   "test-trigger",
+		Rhythm: "@yearly",
+		Func: func(ctx context.Context) error {
+			scheduledAt = ScheduledTime(ctx)
+			return jobErr
+		},
+	})
+
+	err = cron.Trigger(context.Background(), "test-trigger")
+	if !errors.Is(err, jobErr) {
+		t.Fatalf("expected the job error, got %v", err)
+	}
+	if !scheduledAt.Equal(clock.Now()) {
+		t.Errorf("expected scheduled time %v, got %v", clock.Now(), scheduledAt)
+	}
+
+	keys := builder.mutexKeys()
+	if len(keys) != 1 || !strings.HasPrefix(keys[0], "etcd_cron/test_trigger/trigger_") {
+		t.Errorf("unexpected mutex keys %v", keys)
+	}
+	builder.mutex.Lock()
+	locks := len(builder.locks)
+	builder.mutex.Unlock()
+	if locks != 0 {
+		t.Errorf("expected the trigger lock to be released")
+	}
+
+	err = cron.Trigger(context.Background(), "unknown")
+	if !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("expected ErrJobNotFound, got %v", err)
+	}
+}
+
+// Test that Trigger does not run the job once Shutdown has been called.
+func TestTriggerAfterShutdown(t *testing.T) {
+	cron, err := New(WithEtcdMutexBuilder(newMemoryMutexBuilder()))
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	var runs atomic.Int32
+	cron.AddJob(Job{
+		Name:   "test-trigger-shutdown",
+		Rhythm: "@yearly",
+		Func: func(context.Context) error {
+			runs.Add(1)
+			return nil
+		},
+	})
+	cron.Start(context.Background())
+
+	err = cron.Shutdown(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = cron.Trigger(context.Background(), "test-trigger-shutdown")
+	if !errors.Is(err, ErrShuttingDown) {
+		t.Fatalf("expected ErrShuttingDown, got %v", err)
+	}
+	if runs.Load() != 0 {
+		t.Errorf("expected the job not to run")
+	}
+}
+
+// Test that Trigger respects the overlap policy of the job.
+func TestTriggerOverlap(t *testing.T) {
+	cron, err := New(WithEtcdMutexBuilder(newMemoryMutexBuilder()))
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	cron.AddJob(Job{
+		Name:    "test-trigger-overlap",
+		Rhythm:  "@yearly",
+		Overlap: OverlapSkip,
+		Func: func(context.Context) error {
+			started <- struct{}{}
+			<-unblock
+			return nil
+		},
+	})
+
+	errs := make(chan error)
+	go func() {
+		errs <- cron.Trigger(context.Background(), "test-trigger-overlap")
+	}()
+	<-started
+
+	err = cron.Trigger(context.Background(), "test-trigger-overlap")
+	if !errors.Is(err, ErrIterationNotRun) {
+		t.Errorf("expected ErrIterationNotRun, got %v", err)
+	}
+	close(unblock)
+	if err := <-errs; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestTriggerPanic(t *testing.T) {
+	cron, err := New(WithEtcdMutexBuilder(newMemoryMutexBuilder()))
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+	cron.AddJob(Job{
+		Name:   "test-trigger-panic",
+		Rhythm: "@yearly",
+		Func: func(context.Context) error {
+			panic("boom")
+		},
+	})
+
+	err = cron.Trigger(context.Background(), "test-trigger-panic")
+	if err == nil || !strings.Contains(err.Error(), "panic: boom") {
+		t.Errorf("expected panic error, got %v", err)
+	}
+}
+
+// Test that a trigger requested to the cluster is run by exactly one node.
+func TestRequestTrigger(t *testing.T) {
+	prefix := fmt.Sprintf("etcd_cron_test_trigger_%d", time.Now().UnixNano())
+	var runs int32
+	crons := make([]*Cron, 3)
+	for i := range crons {
+		cron, err := New(WithKeyPrefix(prefix))
+		if err != nil {
+			t.Fatal("unexpected error")
+		}
+		defer cron.Close()
+		cron.AddJob(Job{
+			Name:   "test-request-trigger",
+			Rhythm: "@yearly",
+			Func: func(context.Context) error {
+				atomic.AddInt32(&runs, 1)
+				return nil
+			},
+		})
+		cron.Start(context.Background())
+		defer cron.Stop()
+		crons[i] = cron
+	}
+	client, _ := crons[0].etcdClient()
+	defer client.Delete(context.Background(), prefix+"/", etcdclient.WithPrefix())
+
+	ctx, cancel := context.WithTimeout(context.Background(), ONE_SECOND)
+	defer cancel()
+	err := crons[0].RequestTrigger(ctx, "test-request-trigger")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	time.Sleep(2 * ONE_SECOND)
+	if n := atomic.LoadInt32(&runs); n != 1 {
+		t.Errorf("expected 1 run, got %d", n)
+	}
+}